@@ -6,6 +6,7 @@ package notify
 
 import (
 	"context"
+	"encoding/json"
 	"math"
 	"net/http"
 	"time"
@@ -16,17 +17,37 @@ import (
 
 func param2Item(p *types.Params) (ret *model.Item) {
 	now := time.Now().Unix()
+	next := now
+	if at := int64(p.SendAt); at > now {
+		next = at
+	}
+	expire := int64(p.ExpireAt)
+	if p.TTL > 0 {
+		if x := next + int64(p.TTL); expire == 0 || x < expire {
+			expire = x
+		}
+	}
 
-	return &model.Item{
+	ret = &model.Item{
 		ID:       p.ID,
 		Driver:   p.Driver,
 		Endpoint: p.Endpoint,
 		Content:  p.Payload,
 		CreateAt: now,
-		NextAt:   now,
+		NextAt:   next,
 		Tried:    0,
 		State:    types.PENDING,
+		Priority: p.Priority,
+		SendAt:   int64(p.SendAt),
+		ExpireAt: expire,
 	}
+	if p.Retry != nil {
+		ret.Retry = *p.Retry
+	}
+	if p.Callback != nil {
+		ret.Callback, _ = json.Marshal(p.Callback)
+	}
+	return
 }
 
 // DefaultScheduler retries every minute at first 10 tries, and doubles wait time each time
@@ -50,39 +71,84 @@ func DefaultScheduler(driver, notifyID string, lastExec time.Time, tried uint32)
 // Parameters are passed in JSON format using HTTP POST request. "Content-Type"
 // header is ignored. The result of request is returned in HTTP status code.
 //
+// /send and /sendOnce are idempotent: sending a notification with existing id
+// again responds 200 if driver, endpoint and payload are identical, or 409
+// with types.Status of the existing one if not.
+//
 // API Endpoints
 //
 //   - /send:       Send notification and retry automatically if not delivered. See
-//                  types. Params struct for details of parameters.
+//                  types. Params struct for details of parameters. Use "send_at"
+//                  to schedule it at later time.
 //   - /sendOnce:   Send notification, does not retry. See Params struct for details
-//                  of parameters.
-//   - /resend:     Force resend a notification, does not retry. The only accpeted
-//                  parameter is {"id": string}.
+//                  of parameters. Use "callback" to be notified when it is done,
+//                  see types.Callback for detail.
+//   - /broadcast:  Send same notification to many targets, see
+//                  types.BroadcastParams for detail. IDs of created
+//                  notifications are returned in JSON array.
+//   - /groupStatus: Retrieve aggregated status of a broadcast, see
+//                  types.GroupStatus for detail. It accepts only one parameter
+//                  {"id": string}.
+//   - /resend:     Force resend a notification, does not retry. Its retry policy
+//                  is kept for /requeue. Responds 409 if worker is sending it.
+//                  The only accpeted parameter is {"id": string}.
 //   - /result:     Retrieve latest sending result. The only accpeted parameter is
 //                  {"id": string}.
 //   - /status:     Retrieve status of a notification, see types.Status for detail.
 //                  It accepts only one parameter {"id": string}.
 //   - /detail:     Retrieve detail of a notification, see types.Detail for detail.
 //                  It accepts only one parameter {"id": string}.
-//   - /delete:     Deletes a notification, does not interrupt if worker is sending
-//                  it. The only accpeted parameter is {"id": string}.
-//   - /clear:      Deletes outdated, finished jobs (status IN(SUCCESS, FAILED)).
+//   - /attempts:   Retrieve every try of a notification, see types.Attempt for
+//                  detail. It accepts only one parameter {"id": string}.
+//   - /delete:     Deletes a notification, responds 409 if worker is sending it.
+//                  The only accpeted parameter is {"id": string}.
+//   - /clear:      Deletes outdated, finished jobs (status IN(SUCCESS, FAILED,
+//                  EXPIRED)).
 //                  The only accepted parameter is {"before": unix timestamp}.
 //   - /forceClear: Deletes all outdated jobs
 //                  The only accepted parameter is {"before": unix timestamp}.
+//   - /failed:     Lists FAILED notifications, see types.Filter for parameters
+//                  and types.Failed for detail.
+//   - /requeue:    Moves all FAILED notifications matching types.Filter back to
+//                  PENDING with a fresh retry budget. The filter must not be
+//                  empty unless "all" is true. Number of notifications moved
+//                  is returned in {"count": int} format.
+//   - /breakers:   Lists circuit breakers which are tracking failures, see
+//                  types.BreakerStatus for detail. No parameter is needed.
+//   - /pause:      Stops sending notifications of a driver type without losing
+//                  them. The only accepted parameter is {"type": string}, empty
+//                  type pauses all drivers.
+//   - /resume:     Reverts /pause, accepts same parameter. Empty type resumes
+//                  every paused driver.
+//   - /paused:     Lists paused drivers, including those quarantined for
+//                  panicking (see SenderOptions.QuarantineThreshold). See
+//                  types.PauseStatus for detail. No parameter is needed.
+//   - /healthz:    Reports whether the worker is running, see types.Health for
+//                  detail. Responds 503 if not. No parameter is needed.
+//   - /readyz:     Same as /healthz, but also checks db connection and drivers
+//                  implementing types.HealthChecker. No parameter is needed.
+//   - /metrics:    Exports metrics in prometheus text format, available only if
+//                  SenderOptions.Metrics is true. No parameter is needed.
 //
-// Jobs allocated by a worker will not be deleted by /delete, /clear nor /forceClear.
+// Jobs claimed by any worker (see SenderOptions.LeaseTime) will not be deleted
+// by /delete, /clear nor /forceClear.
 type APIServer interface {
-	// register supported drivers, you *MUST* register all needed drivers
-	// before starting server.
+	// register supported drivers, it is safe to call after starting server.
+	// Driver of same type is replaced. CallbackDriver is reserved and
+	// ignored.
 	Register(types.Driver)
+	// stop supporting driver of typ. Its pending notifications are kept in
+	// db until it is registered again.
+	Unregister(typ string)
 	// start the api server and bind it to addr. It also starts internal worker
 	// to send notification.
 	Start() error
 	// start the api server and bind it to addr, with basic TLS settings. It
 	// also starts internal worker to send notification.
 	StartTLS(certFile, keyFile string) error
-	// gracefully shutdown the api server and internal worker.
+	// gracefully shutdown the api server and internal worker. Notifications
+	// being sent by types.ContextDriver are aborted if ctx is done before
+	// they finish.
 	Shutdown(ctx context.Context) (err error)
 	// returns the http.Server so you can customize it. Do not start it by
 	// yourself, or internal worker will not start.
@@ -96,9 +162,15 @@ func NewAPI(opt SenderOptions) (ret APIServer, err error) {
 		return
 	}
 	x := &api{
-		srv:    &http.Server{},
-		sender: s,
-		DBDrv:  opt.DBDrv,
+		srv:     &http.Server{},
+		sender:  s,
+		log:     opt.Logger,
+		metrics: opt.Metrics,
+		Hooks:   opt.Hooks,
+		DBDrv:   opt.DBDrv,
+	}
+	if x.log == nil {
+		x.log = nopLogger{}
 	}
 	x.srv.Handler = x.getMux()
 	return x, nil
@@ -107,9 +179,17 @@ func NewAPI(opt SenderOptions) (ret APIServer, err error) {
 type api struct {
 	srv    *http.Server
 	sender sender
+	log    Logger
+	// enables /metrics
+	metrics bool
+	Hooks
 	model.DBDrv
 }
 
 func (a *api) Register(d types.Driver) {
 	a.sender.Register(d)
 }
+
+func (a *api) Unregister(typ string) {
+	a.sender.Unregister(typ)
+}
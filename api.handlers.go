@@ -5,8 +5,10 @@
 package notify
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -16,6 +18,28 @@ import (
 	"github.com/raohwork/notify/types"
 )
 
+var errMissingID = errors.New("missing required parameter")
+
+// badRequest logs and responds 400
+func (a *api) badRequest(w http.ResponseWriter, r *http.Request, err error) {
+	a.log.Warn("invalid request", "path", r.URL.Path, "error", err)
+	w.WriteHeader(400)
+}
+
+// dbError responds 404 if err is model.E404, or logs it and responds 500
+func (a *api) dbError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if _, ok := err.(*model.E404); ok {
+		w.WriteHeader(404)
+		return
+	}
+	if _, ok := err.(*model.E409); ok {
+		w.WriteHeader(409)
+		return
+	}
+	a.log.Error("db error", "path", r.URL.Path, "id", id, "error", err)
+	w.WriteHeader(500)
+}
+
 func (a *api) toItem(r *http.Request) (ret *model.Item, err error) {
 	defer r.Body.Close()
 	defer io.Copy(ioutil.Discard, r.Body)
@@ -25,8 +49,13 @@ func (a *api) toItem(r *http.Request) (ret *model.Item, err error) {
 		return
 	}
 
+	return a.checkItem(&p)
+}
+
+// checkItem validates p and converts it to model.Item
+func (a *api) checkItem(p *types.Params) (ret *model.Item, err error) {
 	if p.ID == "" || p.Driver == "" {
-		err = errors.New("missing required parameter")
+		err = errMissingID
 		return
 	}
 
@@ -41,38 +70,250 @@ func (a *api) toItem(r *http.Request) (ret *model.Item, err error) {
 		return
 	}
 
-	ret = param2Item(&p)
+	if p.Retry != nil {
+		if err = checkPolicy(p.Retry); err != nil {
+			return
+		}
+	}
+
+	if p.Callback != nil {
+		if err = (&cbDrv{}).CheckEP(p.Callback.URL); err != nil {
+			return
+		}
+	}
+
+	ret = param2Item(p)
 	return
 }
 
-func (a *api) sendH(w http.ResponseWriter, r *http.Request) {
-	i, err := a.toItem(r)
+// create saves i to db, or checks if it is replayed when id exists
+func (a *api) create(w http.ResponseWriter, r *http.Request, i *model.Item) {
+	err := a.Create(i)
+	if _, ok := err.(*model.E409); ok {
+		a.duplicated(w, r, i)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(400)
+		a.log.Error("cannot create notification", itemArgs(i, "error", err)...)
+		w.WriteHeader(500)
 		return
 	}
+	a.log.Debug("created notification", itemArgs(i)...)
+	a.created(i)
+	a.sender.wakeup()
+}
 
-	err = a.Create(i)
+// duplicated responds 200 if i is identical to existing one, or 409 with its
+// status
+func (a *api) duplicated(w http.ResponseWriter, r *http.Request, i *model.Item) {
+	d, same, err := a.identical(i)
 	if err != nil {
-		// cannot save to db, might be duplicated or just db error
-		w.WriteHeader(500)
+		a.dbError(w, r, i.ID, err)
+		return
+	}
+	if same {
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	buf, _ := json.Marshal(d.Status)
+	w.Write(buf)
+}
+
+// identical checks if i is identical to existing notification with same id
+func (a *api) identical(i *model.Item) (ret types.Detail, same bool, err error) {
+	ret, err = a.Detail(i.ID)
+	if err != nil {
+		return
+	}
+
+	same = ret.Driver == i.Driver && ret.Endpoint == i.Endpoint && samePayload(ret.Content, i.Content)
+	if same {
+		a.log.Debug("replayed notification", itemArgs(i)...)
+	} else {
+		a.log.Warn("duplicated notification id", itemArgs(i)...)
+	}
+	return
+}
+
+// samePayload compares json payloads, ignoring insignificant spaces
+func samePayload(a, b []byte) (ret bool) {
+	x, y := &bytes.Buffer{}, &bytes.Buffer{}
+	if json.Compact(x, a) != nil || json.Compact(y, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(x.Bytes(), y.Bytes())
+}
+
+func (a *api) sendH(w http.ResponseWriter, r *http.Request) {
+	i, err := a.toItem(r)
+	if err != nil {
+		a.badRequest(w, r, err)
+		return
+	}
+
+	a.create(w, r, i)
 }
 
 func (a *api) sendOnceH(w http.ResponseWriter, r *http.Request) {
 	i, err := a.toItem(r)
 	if err != nil {
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
-	i.Tried = a.sender.maxRetry() - 1
+	i.Retry = types.RetryPolicy{MaxTries: 1}
+
+	a.create(w, r, i)
+}
+
+// max number of targets of /broadcast, see childID
+const maxTargets = 1000
 
-	err = a.Create(i)
+// childID computes id of idx-th notification of broadcast group
+func childID(group string, idx int) (ret string) {
+	return fmt.Sprintf("%s#%03d", group, idx)
+}
+
+func (a *api) broadcastH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	var p types.BroadcastParams
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&p); err != nil {
+		// incorrect format
+		a.badRequest(w, r, err)
+		return
+	}
+	if p.ID == "" || len(p.Targets) == 0 {
+		// missing basic parameter
+		a.badRequest(w, r, errMissingID)
+		return
+	}
+	if len(p.Targets) > maxTargets || len(childID(p.ID, 0)) > 128 {
+		a.badRequest(w, r, errors.New("too many targets or group id too long"))
+		return
+	}
+
+	// validates all targets before creating any of them
+	items := make([]*model.Item, 0, len(p.Targets))
+	for idx, t := range p.Targets {
+		x := p.Params
+		x.ID = childID(p.ID, idx)
+		x.Driver, x.Endpoint, x.Payload = t.Driver, t.Endpoint, t.Payload
+		i, err := a.checkItem(&x)
+		if err != nil {
+			a.badRequest(w, r, err)
+			return
+		}
+		i.Group = p.ID
+		items = append(items, i)
+	}
+
+	// children created by previous request are kept if failed, so it is
+	// safe to retry
+	ids := make([]string, 0, len(items))
+	for _, i := range items {
+		err := a.Create(i)
+		if _, ok := err.(*model.E409); ok {
+			d, same, err := a.identical(i)
+			if err != nil {
+				a.dbError(w, r, i.ID, err)
+				return
+			}
+			if !same {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				buf, _ := json.Marshal(types.GroupMember{
+					ID:       i.ID,
+					Driver:   d.Driver,
+					Endpoint: d.Endpoint,
+					Status:   d.Status,
+				})
+				w.Write(buf)
+				return
+			}
+			ids = append(ids, i.ID)
+			continue
+		}
+		if err != nil {
+			a.log.Error("cannot create notification", itemArgs(i, "group", p.ID, "error", err)...)
+			w.WriteHeader(500)
+			return
+		}
+		a.log.Debug("created notification", itemArgs(i, "group", p.ID)...)
+		a.created(i)
+		ids = append(ids, i.ID)
+	}
+	a.sender.wakeup()
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(ids)
+	w.Write(buf)
+}
+
+func (a *api) groupStatusH(w http.ResponseWriter, r *http.Request) {
+	var p struct {
+		ID string `json:"id"`
+	}
+
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&p); err != nil {
+		// incorrect format
+		a.badRequest(w, r, err)
+		return
+	}
+
+	if p.ID == "" {
+		// missing basic parameter
+		a.badRequest(w, r, errMissingID)
+		return
+	}
+
+	arr, err := a.Group(p.ID)
+	if err == nil && len(arr) == 0 {
+		err = &model.E404{}
+	}
 	if err != nil {
-		// cannot save to db, might be duplicated or just db error
-		w.WriteHeader(500)
+		a.dbError(w, r, p.ID, err)
 		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(groupStatus(arr))
+	w.Write(buf)
+}
+
+// groupStatus aggregates states of members
+func groupStatus(members []types.GroupMember) (ret types.GroupStatus) {
+	ret.Members = members
+	for _, m := range members {
+		switch m.State {
+		case types.PENDING:
+			ret.Pending++
+		case types.SUCCESS:
+			ret.Success++
+		case types.FAILED:
+			ret.Failed++
+		case types.EXPIRED:
+			ret.Expired++
+		}
+	}
+
+	switch {
+	case ret.Pending > 0:
+		ret.State = types.GroupPending
+	case ret.Failed+ret.Expired == 0:
+		ret.State = types.GroupSucceeded
+	case ret.Success > 0:
+		ret.State = types.GroupPartial
+	default:
+		ret.State = types.GroupFailed
+	}
+	return
 }
 
 func (a *api) resendH(w http.ResponseWriter, r *http.Request) {
@@ -85,25 +326,21 @@ func (a *api) resendH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	if p.ID == "" {
 		// missing basic parameter
-		w.WriteHeader(400)
+		a.badRequest(w, r, errMissingID)
 		return
 	}
 
-	if err := a.Resend(p.ID, a.sender.maxRetry()); err != nil {
-		// not found or just db error
-		if _, ok := err.(*model.E404); ok {
-			w.WriteHeader(404)
-		} else {
-			w.WriteHeader(500)
-		}
+	if err := a.Resend(p.ID); err != nil {
+		a.dbError(w, r, p.ID, err)
 		return
 	}
+	a.sender.wakeup()
 }
 
 func (a *api) statusH(w http.ResponseWriter, r *http.Request) {
@@ -116,23 +353,54 @@ func (a *api) statusH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	if p.ID == "" {
 		// missing basic parameter
-		w.WriteHeader(400)
+		a.badRequest(w, r, errMissingID)
 		return
 	}
 
 	ret, err := a.Status(p.ID)
 	if err != nil {
-		if _, ok := err.(*model.E404); ok {
-			w.WriteHeader(404)
-		} else {
-			w.WriteHeader(500)
-		}
+		a.dbError(w, r, p.ID, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(ret)
+	w.Write(buf)
+}
+
+func (a *api) attemptsH(w http.ResponseWriter, r *http.Request) {
+	var p struct {
+		ID string `json:"id"`
+	}
+
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&p); err != nil {
+		// incorrect format
+		a.badRequest(w, r, err)
+		return
+	}
+
+	if p.ID == "" {
+		// missing basic parameter
+		a.badRequest(w, r, errMissingID)
+		return
+	}
+
+	ret, err := a.Attempts(p.ID)
+	if err == nil && len(ret) == 0 {
+		// distinguish not found from never sent
+		_, err = a.Status(p.ID)
+	}
+	if err != nil {
+		a.dbError(w, r, p.ID, err)
 		return
 	}
 
@@ -151,23 +419,19 @@ func (a *api) detailH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	if p.ID == "" {
 		// missing basic parameter
-		w.WriteHeader(400)
+		a.badRequest(w, r, errMissingID)
 		return
 	}
 
 	ret, err := a.Detail(p.ID)
 	if err != nil {
-		if _, ok := err.(*model.E404); ok {
-			w.WriteHeader(404)
-		} else {
-			w.WriteHeader(500)
-		}
+		a.dbError(w, r, p.ID, err)
 		return
 	}
 
@@ -186,23 +450,19 @@ func (a *api) resultH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	if p.ID == "" {
 		// missing basic parameter
-		w.WriteHeader(400)
+		a.badRequest(w, r, errMissingID)
 		return
 	}
 
 	resp, err := a.Result(p.ID)
 	if err != nil {
-		if _, ok := err.(*model.E404); ok {
-			w.WriteHeader(404)
-		} else {
-			w.WriteHeader(500)
-		}
+		a.dbError(w, r, p.ID, err)
 		return
 	}
 
@@ -220,25 +480,37 @@ func (a *api) deleteH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	if p.ID == "" {
 		// missing basic parameter
-		w.WriteHeader(400)
+		a.badRequest(w, r, errMissingID)
 		return
 	}
 
-	// TODO: log error
-	if err := a.Delete(p.ID, a.sender.curID()); err != nil {
+	var i *model.Item
+	if a.OnDeleted != nil {
+		var err error
+		i, err = a.Item(p.ID)
 		if _, ok := err.(*model.E404); ok {
-			w.WriteHeader(404)
-		} else {
-			w.WriteHeader(500)
+			// nothing to delete, respond same as without hook
+			i, err = nil, nil
 		}
+		if err != nil {
+			a.dbError(w, r, p.ID, err)
+			return
+		}
+	}
+
+	if err := a.Delete(p.ID); err != nil {
+		a.dbError(w, r, p.ID, err)
 		return
 	}
+	if i != nil {
+		a.deleted(i)
+	}
 }
 
 func (a *api) clearH(w http.ResponseWriter, r *http.Request) {
@@ -251,12 +523,13 @@ func (a *api) clearH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	t := time.Unix(p.Before, 0)
-	if err := a.Clear(t, a.sender.curID()); err != nil {
+	if err := a.Clear(t); err != nil {
+		a.log.Error("cannot clear notifications", "path", r.URL.Path, "before", p.Before, "error", err)
 		w.WriteHeader(500)
 	}
 }
@@ -271,12 +544,172 @@ func (a *api) forceClearH(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&p); err != nil {
 		// incorrect format
-		w.WriteHeader(400)
+		a.badRequest(w, r, err)
 		return
 	}
 
 	t := time.Unix(p.Before, 0)
-	if err := a.ForceClear(t, a.sender.curID()); err != nil {
+	if err := a.ForceClear(t); err != nil {
+		a.log.Error("cannot clear notifications", "path", r.URL.Path, "before", p.Before, "error", err)
 		w.WriteHeader(500)
 	}
 }
+
+// default and max number of results of /failed
+const (
+	defaultFailed = 100
+	maxFailed     = 1000
+)
+
+func (a *api) failedH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	var f types.Filter
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&f); err != nil {
+		// incorrect format
+		a.badRequest(w, r, err)
+		return
+	}
+	if f.Limit == 0 {
+		f.Limit = defaultFailed
+	}
+	if f.Limit > maxFailed {
+		f.Limit = maxFailed
+	}
+
+	ret, err := a.Failed(f)
+	if err != nil {
+		a.log.Error("cannot list failed notifications", "path", r.URL.Path, "error", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(ret)
+	w.Write(buf)
+}
+
+func (a *api) requeueH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	var f types.Filter
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&f); err != nil {
+		// incorrect format
+		a.badRequest(w, r, err)
+		return
+	}
+	if f.Empty() && !f.All {
+		a.badRequest(w, r, errors.New(`empty filter, set "all" to true to requeue everything`))
+		return
+	}
+
+	cnt, err := a.Requeue(f, time.Now().Unix())
+	if err != nil {
+		a.log.Error("cannot requeue notifications", "path", r.URL.Path, "error", err)
+		w.WriteHeader(500)
+		return
+	}
+	a.log.Info("requeued notifications", "path", r.URL.Path, "count", cnt)
+	if cnt > 0 {
+		a.sender.wakeup()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(map[string]int64{"count": cnt})
+	w.Write(buf)
+}
+
+func (a *api) breakersH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(a.sender.breakers())
+	w.Write(buf)
+}
+
+func (a *api) metricsH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	counts, err := a.Count()
+	if err != nil {
+		a.log.Error("cannot count notifications", "path", r.URL.Path, "error", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	a.sender.writeMetrics(w)
+	writeCounts(w, counts)
+}
+
+func (a *api) writeHealth(w http.ResponseWriter, r *http.Request, ready bool) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	h := a.health(r.Context(), ready)
+	w.Header().Set("Content-Type", "application/json")
+	if !h.OK {
+		w.WriteHeader(503)
+	}
+	buf, _ := json.Marshal(h)
+	w.Write(buf)
+}
+
+func (a *api) healthzH(w http.ResponseWriter, r *http.Request) {
+	a.writeHealth(w, r, false)
+}
+
+func (a *api) readyzH(w http.ResponseWriter, r *http.Request) {
+	a.writeHealth(w, r, true)
+}
+
+// pauseType parses parameter of /pause and /resume
+func (a *api) pauseType(w http.ResponseWriter, r *http.Request) (typ string, ok bool) {
+	var p struct {
+		Type string `json:"type"`
+	}
+
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&p); err != nil {
+		// incorrect format
+		a.badRequest(w, r, err)
+		return
+	}
+
+	if p.Type != "" {
+		if _, found := a.sender.driver(p.Type); !found {
+			a.badRequest(w, r, errors.New("unsupported driver: "+p.Type))
+			return
+		}
+	}
+	return p.Type, true
+}
+
+func (a *api) pauseH(w http.ResponseWriter, r *http.Request) {
+	if typ, ok := a.pauseType(w, r); ok {
+		a.sender.pause(typ)
+	}
+}
+
+func (a *api) resumeH(w http.ResponseWriter, r *http.Request) {
+	if typ, ok := a.pauseType(w, r); ok {
+		a.sender.resume(typ)
+	}
+}
+
+func (a *api) pausedH(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	defer io.Copy(ioutil.Discard, r.Body)
+
+	w.Header().Set("Content-Type", "application/json")
+	buf, _ := json.Marshal(a.sender.paused())
+	w.Write(buf)
+}
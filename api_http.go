@@ -18,13 +18,27 @@ func (a *api) getMux() (ret *http.ServeMux) {
 	ret = &http.ServeMux{}
 	ret.HandleFunc("/send", a.sendH)
 	ret.HandleFunc("/sendOnce", a.sendOnceH)
+	ret.HandleFunc("/broadcast", a.broadcastH)
+	ret.HandleFunc("/groupStatus", a.groupStatusH)
 	ret.HandleFunc("/resend", a.resendH)
 	ret.HandleFunc("/result", a.resultH)
 	ret.HandleFunc("/status", a.statusH)
 	ret.HandleFunc("/detail", a.detailH)
+	ret.HandleFunc("/attempts", a.attemptsH)
 	ret.HandleFunc("/delete", a.deleteH)
 	ret.HandleFunc("/clear", a.clearH)
 	ret.HandleFunc("/forceClear", a.forceClearH)
+	ret.HandleFunc("/failed", a.failedH)
+	ret.HandleFunc("/requeue", a.requeueH)
+	ret.HandleFunc("/breakers", a.breakersH)
+	ret.HandleFunc("/pause", a.pauseH)
+	ret.HandleFunc("/resume", a.resumeH)
+	ret.HandleFunc("/paused", a.pausedH)
+	ret.HandleFunc("/healthz", a.healthzH)
+	ret.HandleFunc("/readyz", a.readyzH)
+	if a.metrics {
+		ret.HandleFunc("/metrics", a.metricsH)
+	}
 
 	return
 }
@@ -0,0 +1,164 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// BreakerOptions defines configurations of circuit breakers.
+//
+// The breaker opens after Threshold consecutive failures of an endpoint, and
+// notifications to it are skipped and rescheduled (not counted as a try) until
+// Cooldown passes. Then a notification is sent to probe the endpoint, breaker
+// is closed if it success, or opens again.
+//
+// Endpoints are grouped by types.BreakerKeyer if driver implements it.
+type BreakerOptions struct {
+	// 0 disables circuit breaker
+	Threshold uint32
+	// 0 = 1 minute
+	Cooldown time.Duration
+}
+
+type circuit struct {
+	driver    string
+	failures  uint32
+	openUntil time.Time
+	probing   bool
+	// last time a result is reported, see sweep
+	last time.Time
+}
+
+type breaker struct {
+	opt BreakerOptions
+	m   map[string]*circuit
+	sync.Mutex
+}
+
+func newBreaker(opt BreakerOptions) (ret *breaker) {
+	if opt.Cooldown <= 0 {
+		opt.Cooldown = time.Minute
+	}
+	return &breaker{
+		opt: opt,
+		m:   map[string]*circuit{},
+	}
+}
+
+func breakerKey(drv types.Driver, ep string) (ret string) {
+	if k, ok := drv.(types.BreakerKeyer); ok {
+		return k.BreakerKey(ep)
+	}
+	return drv.Type() + ":" + ep
+}
+
+// allow returns how long to wait if breaker of key is open
+func (b *breaker) allow(key string, now time.Time) (wait time.Duration) {
+	if b.opt.Threshold == 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	c, ok := b.m[key]
+	if !ok || c.failures < b.opt.Threshold {
+		return
+	}
+
+	if wait = c.openUntil.Sub(now); wait > 0 {
+		return
+	}
+	if c.probing {
+		// wait for the probe
+		return b.opt.Cooldown
+	}
+
+	c.probing = true
+	return 0
+}
+
+// cancel gives up the probe acquired by allow, if any
+func (b *breaker) cancel(key string) {
+	b.Lock()
+	defer b.Unlock()
+	if c, ok := b.m[key]; ok {
+		c.probing = false
+	}
+}
+
+// report records sending result of key
+func (b *breaker) report(key, typ string, ok bool, now time.Time) {
+	if b.opt.Threshold == 0 {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	if ok {
+		delete(b.m, key)
+		return
+	}
+
+	c, found := b.m[key]
+	if !found {
+		if len(b.m) >= maxCircuits {
+			b.sweep(now)
+		}
+		c = &circuit{}
+		b.m[key] = c
+	}
+	c.driver = typ
+	c.last = now
+	c.failures++
+	c.probing = false
+	if c.failures >= b.opt.Threshold {
+		c.openUntil = now.Add(b.opt.Cooldown)
+	}
+}
+
+// max number of circuits before removing idle ones
+const maxCircuits = 1024
+
+// sweep removes circuits which are not used for 10 cooldowns, so endpoints
+// never contacted again do not stay forever. Must be called with lock held.
+func (b *breaker) sweep(now time.Time) {
+	idle := 10 * b.opt.Cooldown
+	for k, c := range b.m {
+		if now.Sub(c.last) > idle {
+			delete(b.m, k)
+		}
+	}
+}
+
+func (b *breaker) list(now time.Time) (ret []types.BreakerStatus) {
+	b.Lock()
+	defer b.Unlock()
+
+	ret = make([]types.BreakerStatus, 0, len(b.m))
+	for k, c := range b.m {
+		x := types.BreakerStatus{
+			Key:      k,
+			Driver:   c.driver,
+			Failures: c.failures,
+			State:    "closed",
+		}
+		if c.failures >= b.opt.Threshold {
+			x.State = "open"
+			x.OpenUntil = c.openUntil.Unix()
+			if c.probing || !now.Before(c.openUntil) {
+				x.State = "half-open"
+			}
+		}
+		ret = append(ret, x)
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Key < ret[j].Key })
+	return
+}
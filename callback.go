@@ -0,0 +1,153 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/raohwork/notify/drivers/httpdrv"
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// CallbackDriver is driver type of callbacks, see types.Callback
+const CallbackDriver = "CALLBACK"
+
+type cbMsg struct {
+	Headers http.Header     `json:"headers,omitempty"`
+	Body    json.RawMessage `json:"body"`
+}
+
+type cbDrv struct {
+	cl *http.Client
+}
+
+func (d *cbDrv) Type() (ret string) {
+	return CallbackDriver
+}
+
+func (d *cbDrv) CheckEP(ep string) (err error) {
+	u, err := url.Parse(ep)
+	if err != nil {
+		return
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		err = errors.New("callback url must be absolute http(s) url")
+	}
+	return
+}
+
+func (d *cbDrv) Verify(content []byte) (err error) {
+	var x cbMsg
+	return json.Unmarshal(content, &x)
+}
+
+func (d *cbDrv) Send(ep string, content []byte) (resp []byte, err error) {
+	return d.SendContext(context.Background(), ep, content)
+}
+
+func (d *cbDrv) SendContext(ctx context.Context, ep string, content []byte) (resp []byte, err error) {
+	var msg cbMsg
+	if err = json.Unmarshal(content, &msg); err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ep, bytes.NewReader(msg.Body))
+	if err != nil {
+		return
+	}
+	for k, v := range msg.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := d.cl.Do(req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	resp, _ = ioutil.ReadAll(res.Body)
+
+	err = httpdrv.DefaultValidator(res.StatusCode, res.Header, resp)
+	return
+}
+
+// callbackID computes id of the callback, hashed if too long for db. Tried and
+// state are included so every terminal state after resending gets its own
+// callback, while recreating the same one after crashing is still rejected.
+func callbackID(id string, tried uint32, state types.State) (ret string) {
+	const prefix = "callback:"
+	key := id + "#" + strconv.FormatUint(uint64(tried), 10) + ":" + state.String()
+	if len(prefix)+len(key) <= 128 {
+		return prefix + key
+	}
+
+	h := sha1.Sum([]byte(key))
+	return prefix + hex.EncodeToString(h[:])
+}
+
+// callback creates the callback notification of i if needed. It *MUST* be
+// called before updating state of i, so it will not be lost if the server
+// crashed in between.
+func (o *SenderOptions) callback(i *model.Item, state types.State, resp []byte) {
+	if len(i.Callback) == 0 {
+		return
+	}
+
+	var cb types.Callback
+	if err := json.Unmarshal(i.Callback, &cb); err != nil {
+		o.Logger.Error("invalid callback", itemArgs(i, "error", err)...)
+		return
+	}
+
+	body, _ := json.Marshal(types.CallbackBody{
+		ID:       i.ID,
+		Driver:   i.Driver,
+		Endpoint: i.Endpoint,
+		Response: resp,
+		Status: types.Status{
+			CreateAt: i.CreateAt,
+			NextAt:   i.NextAt,
+			Tried:    i.Tried,
+			State:    state,
+			SendAt:   i.SendAt,
+			ExpireAt: i.ExpireAt,
+		},
+	})
+	content, _ := json.Marshal(cbMsg{Headers: cb.Headers, Body: body})
+
+	now := time.Now().Unix()
+	x := &model.Item{
+		ID:       callbackID(i.ID, i.Tried, state),
+		Driver:   CallbackDriver,
+		Endpoint: cb.URL,
+		Content:  content,
+		CreateAt: now,
+		NextAt:   now,
+		State:    types.PENDING,
+		Priority: i.Priority,
+	}
+	err := o.Create(x)
+	if _, ok := err.(*model.E409); ok {
+		// created before crashing
+		o.Logger.Debug("callback exists", itemArgs(x, "parent", i.ID)...)
+		return
+	}
+	if err != nil {
+		o.Logger.Warn("cannot create callback", itemArgs(x, "parent", i.ID, "error", err)...)
+		return
+	}
+	o.Logger.Debug("created callback", itemArgs(x, "parent", i.ID)...)
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/mail"
 	"net/smtp"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,7 @@ import (
 	"github.com/raohwork/notify/drivers/tgdrv"
 	"github.com/raohwork/notify/model"
 	"github.com/raohwork/notify/model/pgsqldrv"
+	"github.com/raohwork/notify/scheduler"
 	"github.com/raohwork/notify/types"
 )
 
@@ -47,6 +49,17 @@ const (
 	keySMTPAuth    = "SMTP_AUTH"
 	keySMTPTLS     = "SMTP_TLS"
 	keySMTPFrom    = "SMTP_FROM"
+	keyNodeID      = "NODE_ID"
+	keyLeaseTime   = "LEASE_TIME"
+	keyRateLimit   = "RATE_LIMIT"
+	keyRateLimitEP = "RATE_LIMIT_EP"
+	keyBreaker     = "BREAKER_THRESHOLD"
+	keyCooldown    = "BREAKER_COOLDOWN"
+	keyScheduler   = "SCHEDULER"
+	keySchedDrv    = "SCHEDULER_DRIVERS"
+	keyLogDebug    = "LOG_DEBUG"
+	keyMetrics     = "METRICS"
+	keyJournal     = "JOURNAL"
 )
 
 var bind string
@@ -71,6 +84,17 @@ func init() {
 	m.May(keySMTPTLS, "enable tls for smtp if not empty", "")
 	m.May(keySMTPAuth, "smtp auth method, can be PLAIN/CRAMMD5 (case insensitive)", "plain")
 	m.May(keySMTPFrom, "specify From header for smtp", "John Doe <john.doe@example.com>")
+	m.Want(keyNodeID, "unique id of this instance, required if running multiple instances with same db", "node-1")
+	m.May(keyLeaseTime, "seconds to reserve a notification for sending", "300")
+	m.Want(keyRateLimit, "max sends per second of each driver, in driver=rate[:burst] format, separated by comma", "TGMarkdown=30,SMSAV8D=1:5")
+	m.Want(keyRateLimitEP, "same as "+keyRateLimit+", but limits each endpoint of the driver", "TGMarkdown=1:3")
+	m.May(keyBreaker, "skip an endpoint after failed these times in a row, 0 disables it", "0")
+	m.May(keyCooldown, "seconds to skip an endpoint when "+keyBreaker+" is reached", "60")
+	m.Want(keyScheduler, "when to retry, can be fixed:DELAY, exp:BASE[,CAP[,none|full|decorrelated]] or steps:DELAY,DELAY,...", "exp:1m,2h,full")
+	m.May(keyLogDebug, "log every allocation and attempt if not empty", "")
+	m.May(keyMetrics, "enable /metrics in prometheus format if not empty", "")
+	m.May(keyJournal, "file to keep results which cannot be written to db, replayed at startup", "")
+	m.Want(keySchedDrv, "overrides "+keyScheduler+" of specific drivers, separated by semicolon", "TGMarkdown=fixed:30s;SMSAV8D=steps:1m,5m,30m,2h")
 }
 
 func setup(data map[string]string) {
@@ -91,6 +115,34 @@ func setup(data map[string]string) {
 		log.Fatal("THREAD must be positive integer")
 	}
 
+	lease, err := strconv.ParseUint(data[keyLeaseTime], 10, 32)
+	if err != nil || lease == 0 {
+		log.Fatal("LEASE_TIME must be positive integer")
+	}
+
+	rl, err := notify.ParseRateLimits(data[keyRateLimit])
+	if err != nil {
+		log.Fatal(err)
+	}
+	rlEP, err := notify.ParseRateLimits(data[keyRateLimitEP])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	br, err := strconv.ParseUint(data[keyBreaker], 10, 32)
+	if err != nil {
+		log.Fatal("BREAKER_THRESHOLD must be non-negative integer")
+	}
+	cooldown, err := strconv.ParseUint(data[keyCooldown], 10, 32)
+	if err != nil || cooldown == 0 {
+		log.Fatal("BREAKER_COOLDOWN must be positive integer")
+	}
+
+	sched, err := scheduler.ParseMux(data[keyScheduler], data[keySchedDrv])
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	t := time.Duration(15)
 	if str := data[keyHTTPTimeout]; str != "" {
 		x, e := strconv.ParseUint(str, 10, 64)
@@ -103,41 +155,47 @@ func setup(data map[string]string) {
 		Timeout: time.Duration(t) * time.Second,
 	}
 
-	drvs := 2
 	x := make([]types.Driver, 0, 2)
 	if token, target := data[keyTGToken], data[keyTGTarget]; token != "" && target != "" {
 		d := initTG(token, target, cl)
-		if l := len(d); l > 0 {
-			drvs += l
-			x = append(x, d...)
-		}
+		x = append(x, d...)
 	}
 
 	if u, p := data[keyAV8DUser], data[keyAV8DPass]; u != "" && p != "" {
 		log.Print("got username and password, enables smsav8d")
 		d := smsav8d.New(u, p, cl)
-		drvs++
 		x = append(x, d)
 	}
 
 	if sg := data[keySendgridKey]; sg != "" {
 		d := initSendgrid(sg, cl)
 		if d != nil {
-			drvs++
 			x = append(x, d)
 		}
 	}
 
 	smtpdrvs := initSMTP(data)
-	dbdrv, err := pgsqldrv.New(db, drvs+len(smtpdrvs), int(thread))
+	dbdrv, err := pgsqldrv.New(db)
 	if err != nil {
 		log.Fatal("cannot initialize db driver: ", err)
 	}
 
 	api, err = notify.NewAPI(notify.SenderOptions{
-		MaxTries:   uint32(max),
-		MaxThreads: uint16(thread),
-		DBDrv:      dbdrv,
+		MaxTries:           uint32(max),
+		MaxThreads:         uint16(thread),
+		Scheduler:          sched,
+		Logger:             notify.StdLogger(log.New(os.Stderr, "", log.LstdFlags), data[keyLogDebug] != ""),
+		Metrics:            data[keyMetrics] != "",
+		Journal:            data[keyJournal],
+		NodeID:             data[keyNodeID],
+		LeaseTime:          time.Duration(lease) * time.Second,
+		RateLimits:         rl,
+		EndpointRateLimits: rlEP,
+		Breaker: notify.BreakerOptions{
+			Threshold: uint32(br),
+			Cooldown:  time.Duration(cooldown) * time.Second,
+		},
+		DBDrv: dbdrv,
 	})
 	if err != nil {
 		log.Fatal("cannot initialize api server: ", err)
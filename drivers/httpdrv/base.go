@@ -11,6 +11,10 @@ import (
 	"bytes"
 	"errors"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/raohwork/notify/types"
 )
 
 // Validator defines user-provided function to determine if response is success
@@ -18,12 +22,26 @@ import (
 type Validator func(code int, headers http.Header, body []byte) (err error)
 
 // DefaultValidator determines http status code is 2xx, or error is returned
+//
+// The error is a types.RetryAfterError if Retry-After header presents, or a
+// types.PermanentError for 4xx status codes other than 408, 425 and 429.
 func DefaultValidator(code int, headers http.Header, body []byte) (err error) {
 	if code >= 200 && code < 300 {
 		return
 	}
 
-	return errors.New("status code is not 2xx")
+	err = errors.New("status code is not 2xx")
+	if d, ok := types.ParseRetryAfter(headers.Get("Retry-After"), time.Now()); ok {
+		return types.RetryAfter(d, err)
+	}
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return
+	}
+	if code >= 400 && code < 500 {
+		err = types.Permanent(err)
+	}
+	return
 }
 
 // StringValidator creates a Validator which determines response body begins with
@@ -36,3 +54,12 @@ func StringValidator(str string) (ret Validator) {
 		return
 	}
 }
+
+// breakerKey groups endpoints by host, shared by HTTPGet and HTTPPost
+func breakerKey(ep string) (ret string) {
+	u, err := url.Parse(ep)
+	if err != nil {
+		return "HTTP:" + ep
+	}
+	return "HTTP:" + u.Host
+}
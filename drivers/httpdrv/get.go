@@ -5,6 +5,7 @@
 package httpdrv
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -31,6 +32,8 @@ type getDrv struct {
 }
 
 // HTTPGet creates a driver that delivers notification via HTTP GET
+//
+// The driver implements types.ContextDriver.
 func HTTPGet(cl *http.Client, v Validator) (ret types.Driver) {
 	if v == nil {
 		v = DefaultValidator
@@ -53,6 +56,12 @@ func (d *getDrv) CheckEP(ep string) (err error) {
 	return
 }
 
+// BreakerKey implements types.BreakerKeyer, endpoints on same host share one
+// circuit breaker
+func (d *getDrv) BreakerKey(ep string) (ret string) {
+	return breakerKey(ep)
+}
+
 func (d *getDrv) Type() (ret string) {
 	return GET
 }
@@ -75,6 +84,10 @@ func (d *getDrv) extract(buf []byte) (ret *GetMsg, err error) {
 }
 
 func (d *getDrv) Send(ep string, data []byte) (resp []byte, err error) {
+	return d.SendContext(context.Background(), ep, data)
+}
+
+func (d *getDrv) SendContext(ctx context.Context, ep string, data []byte) (resp []byte, err error) {
 	msg, err := d.extract(data)
 	if err != nil {
 		return
@@ -84,7 +97,7 @@ func (d *getDrv) Send(ep string, data []byte) (resp []byte, err error) {
 		ep += "?" + msg.Values.Encode()
 	}
 
-	req, err := http.NewRequest("GET", ep, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", ep, nil)
 	if err != nil {
 		return
 	}
@@ -5,11 +5,15 @@
 package httpdrv
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
 )
 
 func TestGetExtract(t *testing.T) {
@@ -56,3 +60,58 @@ func TestGetSend(t *testing.T) {
 		t.Errorf("unexpected notify: %+v", val)
 	}
 }
+
+func TestGetSendContext(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	d := HTTPGet(http.DefaultClient, nil).(types.ContextDriver)
+	begin := time.Now()
+	if _, err := d.SendContext(ctx, srv.URL+"/", []byte(`{}`)); err == nil {
+		t.Fatal("expected timeout error, got nothing")
+	}
+
+	if dur := time.Since(begin); dur > time.Second {
+		t.Errorf("request is not canceled in time: %s", dur)
+	}
+}
+
+func TestGetSendError(t *testing.T) {
+	h := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/404":
+			w.WriteHeader(404)
+		case "/429":
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(429)
+		default:
+			w.WriteHeader(500)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(h))
+	defer srv.Close()
+	d := HTTPGet(http.DefaultClient, nil)
+
+	_, err := d.Send(srv.URL+"/404", []byte(`{}`))
+	if !types.IsPermanent(err) {
+		t.Errorf("expected permanent error on 404, got %v", err)
+	}
+
+	_, err = d.Send(srv.URL+"/429", []byte(`{}`))
+	if delay, ok := types.RetryDelay(err); !ok || delay != 5*time.Second {
+		t.Errorf("expected to retry after 5s on 429, got %v", err)
+	}
+
+	_, err = d.Send(srv.URL+"/500", []byte(`{}`))
+	if _, ok := types.RetryDelay(err); err == nil || ok || types.IsPermanent(err) {
+		t.Errorf("expected transient error on 500, got %v", err)
+	}
+}
@@ -5,6 +5,7 @@
 package httpdrv
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -53,6 +54,8 @@ type postDrv struct {
 }
 
 // HTTPPost creates a driver that delivers notification via HTTP POST
+//
+// The driver implements types.ContextDriver.
 func HTTPPost(cl *http.Client, v Validator) (ret types.Driver) {
 	if v == nil {
 		v = DefaultValidator
@@ -63,6 +66,12 @@ func HTTPPost(cl *http.Client, v Validator) (ret types.Driver) {
 	}
 }
 
+// BreakerKey implements types.BreakerKeyer, endpoints on same host share one
+// circuit breaker
+func (d *postDrv) BreakerKey(ep string) (ret string) {
+	return breakerKey(ep)
+}
+
 func (d *postDrv) Type() (ret string) {
 	return POST
 }
@@ -90,12 +99,18 @@ func (d *postDrv) extract(buf []byte) (ret *PostMsg, err error) {
 }
 
 func (d *postDrv) Send(ep string, data []byte) (resp []byte, err error) {
+	return d.SendContext(context.Background(), ep, data)
+}
+
+func (d *postDrv) SendContext(ctx context.Context, ep string, data []byte) (resp []byte, err error) {
 	msg, err := d.extract(data)
 	if err != nil {
 		return
 	}
 
-	req, err := http.NewRequest("POST", ep, strings.NewReader(msg.Body))
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", ep, strings.NewReader(msg.Body),
+	)
 	if err != nil {
 		return
 	}
@@ -6,11 +6,15 @@
 package sendgriddrv
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/raohwork/notify/types"
+	"github.com/sendgrid/rest"
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
 )
@@ -20,7 +24,7 @@ import (
 // The payload format is json encoded mail.SGMailV3
 // https://godoc.org/github.com/sendgrid/sendgrid-go/helpers/mail#SGMailV3
 //
-// This driver does not use endpoint info.
+// This driver does not use endpoint info, and implements types.ContextDriver.
 func New(key string, cl *http.Client) (ret types.Driver) {
 	if cl == nil {
 		cl = http.DefaultClient
@@ -71,14 +75,57 @@ func (d *drv) extract(data []byte) (ret *mail.SGMailV3, err error) {
 }
 
 func (d *drv) Send(ep string, content []byte) (resp []byte, err error) {
+	return d.SendContext(context.Background(), ep, content)
+}
+
+func (d *drv) SendContext(ctx context.Context, ep string, content []byte) (resp []byte, err error) {
 	m, err := d.extract(content)
 	if err != nil {
 		return
 	}
 
-	cl := sendgrid.NewSendClient(d.key)
-	sgres, err := cl.Send(m)
+	r := sendgrid.GetRequest(d.key, "/v3/mail/send", "")
+	r.Method = "POST"
+	r.Body = mail.GetRequestBody(m)
+	req, err := rest.BuildRequestObject(r)
+	if err != nil {
+		return
+	}
+
+	res, err := d.hc.Do(req.WithContext(ctx))
+	if err != nil {
+		return
+	}
+	sgres, err := rest.BuildResponse(res)
 	resp, _ = json.Marshal(sgres)
+	if err == nil {
+		err = statusError(sgres, time.Now())
+	}
+
+	return
+}
 
+// statusError classifies failed response, see
+// https://docs.sendgrid.com/api-reference/how-to-use-the-sendgrid-v3-api/responses
+func statusError(res *rest.Response, now time.Time) (err error) {
+	code := res.StatusCode
+	if code >= 200 && code < 300 {
+		return
+	}
+
+	err = errors.New("sendgrid returns status code " + strconv.Itoa(code))
+	switch {
+	case code == http.StatusTooManyRequests:
+		// X-RateLimit-Reset is the time when rate limit resets
+		h := http.Header(res.Headers)
+		reset, e := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+		if e == nil && reset > now.Unix() {
+			err = types.RetryAfter(time.Unix(reset, 0).Sub(now), err)
+		}
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		// invalid api key or permission, can be fixed by admin
+	case code >= 400 && code < 500:
+		err = types.Permanent(err)
+	}
 	return
 }
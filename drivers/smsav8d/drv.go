@@ -7,6 +7,7 @@
 package smsav8d
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -49,7 +50,7 @@ type drv struct {
 // New creates a driver to send SMS
 //
 // The format of payload is defined by Message struct, and endpoint is same as
-// "DEST" field in official API doc.
+// "DEST" field in official API doc. The driver implements types.ContextDriver.
 func New(account, password string, hc *http.Client) (ret types.Driver) {
 	if hc == nil {
 		hc = http.DefaultClient
@@ -96,6 +97,10 @@ func (d *drv) Verify(data []byte) (err error) {
 }
 
 func (d *drv) Send(ep string, content []byte) (resp []byte, err error) {
+	return d.SendContext(context.Background(), ep, content)
+}
+
+func (d *drv) SendContext(ctx context.Context, ep string, content []byte) (resp []byte, err error) {
 	m, err := d.extract(content)
 	if err != nil {
 		return
@@ -113,7 +118,11 @@ func (d *drv) Send(ep string, content []byte) (resp []byte, err error) {
 	}
 
 	uri := "https://oms.every8d.com/API21/HTTP/sendSMS.ashx?" + val.Encode()
-	res, err := d.hc.Get(uri)
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return
+	}
+	res, err := d.hc.Do(req)
 	if err != nil {
 		return
 	}
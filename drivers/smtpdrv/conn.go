@@ -5,7 +5,9 @@
 package smtpdrv
 
 import (
+	"context"
 	"crypto/tls"
+	"net"
 	"net/smtp"
 )
 
@@ -20,32 +22,72 @@ func (c *conn) release(cl *smtp.Client) {
 	c.ch <- cl
 }
 
-func (c *conn) alloc() (ret *smtp.Client, err error) {
-	x := <-c.ch
-	if x == nil {
-		return c.dial()
+// alloc takes the pooled connection, caller *MUST* release it (or nil if it
+// is broken) if no error is returned
+func (c *conn) alloc(ctx context.Context) (ret *smtp.Client, err error) {
+	var x *smtp.Client
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	case x = <-c.ch:
 	}
-	if err = x.Noop(); err != nil {
+
+	return c.check(ctx, x)
+}
+
+// tryAlloc is same as alloc, but returns immediately with ok=false if the
+// pooled connection is in use
+func (c *conn) tryAlloc(ctx context.Context) (ret *smtp.Client, ok bool, err error) {
+	var x *smtp.Client
+	select {
+	case x = <-c.ch:
+	default:
+		return
+	}
+
+	ok = true
+	ret, err = c.check(ctx, x)
+	return
+}
+
+// check returns x if it still works, or dials a new connection
+func (c *conn) check(ctx context.Context, x *smtp.Client) (ret *smtp.Client, err error) {
+	if x != nil {
+		if err = x.Noop(); err == nil {
+			ret = x
+			return
+		}
 		x.Close()
-		return c.dial()
 	}
 
-	ret = x
+	if ret, err = c.dial(ctx); err != nil {
+		c.release(nil)
+	}
 	return
 }
 
-func (c *conn) dial() (ret *smtp.Client, err error) {
-	x, err := smtp.Dial(c.addr)
+func (c *conn) dial(ctx context.Context) (ret *smtp.Client, err error) {
+	d := &net.Dialer{}
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
 	if err != nil {
 		return
 	}
+	host, _, _ := net.SplitHostPort(c.addr)
+	x, err := smtp.NewClient(nc, host)
+	if err != nil {
+		nc.Close()
+		return
+	}
 	if c.tlsHost != "" {
 		err = x.StartTLS(&tls.Config{ServerName: c.tlsHost})
 		if err != nil {
+			x.Close()
 			return
 		}
 	}
 	if err = x.Auth(c.auth); err != nil {
+		x.Close()
 		return
 	}
 
@@ -10,13 +10,18 @@
 //
 // Take a look at TestPayloadAttach(), it also demonstrates how to embed images in
 // html email (works in some popular clients including gmail web/app).
+//
+// Drivers in this package implement types.ContextDriver and
+// types.HealthChecker.
 package smtpdrv
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 
 	"github.com/raohwork/notify/types"
@@ -106,17 +111,55 @@ func genlist(arr []mail.Address) (ret string) {
 	return strings.Join(lst, ", ")
 }
 
+// Health implements types.HealthChecker by sending NOOP on pooled connection,
+// or dialing a new one if it is broken. The connection is healthy if it is
+// being used to send mails.
+func (d *drv) Health(ctx context.Context) (err error) {
+	c, ok, err := d.conn.tryAlloc(ctx)
+	if !ok || err != nil {
+		return
+	}
+	d.conn.release(c)
+	return
+}
+
 func (d *drv) Send(ep string, content []byte) (resp []byte, err error) {
+	return d.SendContext(context.Background(), ep, content)
+}
+
+// SendContext sends the mail. net/smtp does not support context, so the
+// connection is closed to abort sending when ctx is done.
+func (d *drv) SendContext(ctx context.Context, ep string, content []byte) (resp []byte, err error) {
 	m, err := d.extract(content)
 	if err != nil {
 		return
 	}
 
-	c, err := d.conn.alloc()
+	c, err := d.conn.alloc(ctx)
 	if err != nil {
 		return
 	}
-	defer d.conn.release(c)
+	// errors of dialing and auth are not classified, they can be fixed by admin
+	defer func() { err = classify(err) }()
+
+	done, aborted := make(chan struct{}), make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+			aborted <- true
+		case <-done:
+			aborted <- false
+		}
+	}()
+	defer func() {
+		close(done)
+		if <-aborted {
+			// closed, drop it
+			c = nil
+		}
+		d.conn.release(c)
+	}()
 
 	if err = c.Mail(d.from.String()); err != nil {
 		return
@@ -144,3 +187,18 @@ func (d *drv) Send(ep string, content []byte) (resp []byte, err error) {
 
 	return
 }
+
+// classify marks replies caused by recipient or message as permanent, like 550
+// mailbox unavailable. Others like 530/535 (authentication) are retried as they
+// are fixed by configuring the server.
+func classify(err error) (ret error) {
+	var e *textproto.Error
+	if !errors.As(err, &e) {
+		return err
+	}
+	switch e.Code {
+	case 550, 551, 552, 553, 555:
+		return types.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package smtpdrv
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+func TestClassify(t *testing.T) {
+	cases := map[int]bool{
+		421: false,
+		450: false,
+		530: false, // authentication required
+		535: false, // authentication failed
+		554: false,
+		550: true,
+		551: true,
+		552: true,
+		553: true,
+		555: true,
+	}
+	for code, perm := range cases {
+		err := classify(&textproto.Error{Code: code, Msg: "test"})
+		if types.IsPermanent(err) != perm {
+			t.Errorf("expected permanent of %d to be %v", code, perm)
+		}
+	}
+
+	if err := classify(errors.New("network")); types.IsPermanent(err) {
+		t.Error("non-smtp error should not be permanent")
+	}
+}
+
+func TestHealthBusy(t *testing.T) {
+	d := gen(mail.Address{}, "127.0.0.1:1", nil, "")
+	// connection is being used to send mail
+	<-d.conn.ch
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := d.Health(ctx); err != nil {
+		t.Errorf("expected busy connection to be healthy, got %v", err)
+	}
+}
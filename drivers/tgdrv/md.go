@@ -3,9 +3,13 @@
  * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
 
 // Package tgdrv provides a driver that send telegram message
+//
+// All drivers in this package implement types.ContextDriver and
+// types.HealthChecker.
 package tgdrv
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -14,6 +18,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/raohwork/notify/types"
 )
@@ -31,8 +36,8 @@ func uri(token, ep string) (ret string) {
 	return "https://api.telegram.org/bot" + token + "/" + ep
 }
 
-func validateToken(token string, cl *http.Client) (err error) {
-	req, err := http.NewRequest("GET", uri(token, "getMe"), nil)
+func validateToken(ctx context.Context, token string, cl *http.Client) (err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", uri(token, "getMe"), nil)
 	if err != nil {
 		return
 	}
@@ -66,7 +71,7 @@ func validateToken(token string, cl *http.Client) (err error) {
 //
 // dest maps endpoint to chat/channel/user ids.
 func Markdown(token string, dest map[string]int64, cl *http.Client) (ret types.Driver, err error) {
-	if err = validateToken(token, cl); err != nil {
+	if err = validateToken(context.Background(), token, cl); err != nil {
 		err = errors.New("cannot validate telegram bot token: " + err.Error())
 		return
 	}
@@ -88,7 +93,7 @@ func Markdown(token string, dest map[string]int64, cl *http.Client) (ret types.D
 //
 // dest maps endpoint to chat/channel/user ids.
 func HTML(token string, dest map[string]int64, cl *http.Client) (ret types.Driver, err error) {
-	if err = validateToken(token, cl); err != nil {
+	if err = validateToken(context.Background(), token, cl); err != nil {
 		err = errors.New("cannot validate telegram bot token: " + err.Error())
 		return
 	}
@@ -110,7 +115,7 @@ func HTML(token string, dest map[string]int64, cl *http.Client) (ret types.Drive
 //
 // dest maps endpoint to chat/channel/user ids.
 func Plain(token string, dest map[string]int64, cl *http.Client) (ret types.Driver, err error) {
-	if err = validateToken(token, cl); err != nil {
+	if err = validateToken(context.Background(), token, cl); err != nil {
 		err = errors.New("cannot validate telegram bot token: " + err.Error())
 		return
 	}
@@ -137,6 +142,11 @@ func (t *tgTxt) Type() (ret string) {
 	return t.typ
 }
 
+// Health implements types.HealthChecker by validating the token with getMe
+func (t *tgTxt) Health(ctx context.Context) (err error) {
+	return validateToken(ctx, t.token, t.cl)
+}
+
 func (t *tgTxt) CheckEP(ep string) (err error) {
 	if _, ok := t.dest[ep]; !ok {
 		err = errors.New("unsupported dest: " + ep)
@@ -160,6 +170,10 @@ func (t *tgTxt) extract(data []byte) (msg string, err error) {
 }
 
 func (t *tgTxt) Send(ep string, content []byte) (resp []byte, err error) {
+	return t.SendContext(context.Background(), ep, content)
+}
+
+func (t *tgTxt) SendContext(ctx context.Context, ep string, content []byte) (resp []byte, err error) {
 	cid, ok := t.dest[ep]
 	if !ok {
 		err = errors.New("unsupported dest: " + ep)
@@ -178,8 +192,8 @@ func (t *tgTxt) Send(ep string, content []byte) (resp []byte, err error) {
 		val.Set("parse_mode", t.parse)
 	}
 
-	req, err := http.NewRequest(
-		"POST", uri(t.token, "sendMessage"),
+	req, err := http.NewRequestWithContext(
+		ctx, "POST", uri(t.token, "sendMessage"),
 		strings.NewReader(val.Encode()),
 	)
 	if err != nil {
@@ -188,19 +202,40 @@ func (t *tgTxt) Send(ep string, content []byte) (resp []byte, err error) {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	res, err := t.cl.Do(req)
-	if err == nil || res.Body != nil {
-		resp, _ = ioutil.ReadAll(res.Body)
-		var x struct {
-			OK   bool   `json:"ok"`
-			Desc string `json:"description,omitempty"`
-		}
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	resp, _ = ioutil.ReadAll(res.Body)
+	var x struct {
+		OK     bool   `json:"ok"`
+		Desc   string `json:"description,omitempty"`
+		Code   int    `json:"error_code,omitempty"`
+		Params struct {
+			RetryAfter int `json:"retry_after,omitempty"`
+		} `json:"parameters,omitempty"`
+	}
 
-		err = json.Unmarshal(resp, &x)
-		if err == nil && !x.OK {
-			err = errors.New("cannot send message: " + x.Desc)
-		}
-		res.Body.Close()
+	err = json.Unmarshal(resp, &x)
+	if err == nil && !x.OK {
+		err = apiError(x.Code, x.Desc, x.Params.RetryAfter)
 	}
+	return
+}
 
+// apiError classifies error responses of bot api, see
+// https://core.telegram.org/bots/api#responseparameters
+func apiError(code int, desc string, retryAfter int) (err error) {
+	err = errors.New("cannot send message: " + desc)
+	switch {
+	case retryAfter > 0:
+		err = types.RetryAfter(time.Duration(retryAfter)*time.Second, err)
+	case code == http.StatusUnauthorized, code == http.StatusTooManyRequests:
+		// invalid token can be fixed by admin
+	case code >= 400 && code < 500:
+		// bad request, blocked by user, chat not found...
+		err = types.Permanent(err)
+	}
 	return
 }
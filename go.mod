@@ -10,7 +10,7 @@ require (
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/jackc/pgx/v4 v4.9.0
 	github.com/raohwork/envexist v0.1.0
-	github.com/sendgrid/rest v2.6.1+incompatible // indirect
+	github.com/sendgrid/rest v2.6.1+incompatible
 	github.com/sendgrid/sendgrid-go v3.6.4+incompatible
 	github.com/stretchr/testify v1.6.1 // indirect
 	golang.org/x/net v0.0.0-20201006153459-a7d1128ccaa0
@@ -0,0 +1,71 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// how long /readyz waits for db and drivers
+const healthTimeout = 5 * time.Second
+
+func componentHealth(err error) (ret types.ComponentHealth) {
+	ret.OK = err == nil
+	if err != nil {
+		ret.Error = err.Error()
+	}
+	return
+}
+
+// health checks the worker, and also db and drivers if ready is true
+func (a *api) health(ctx context.Context, ready bool) (ret types.Health) {
+	ret.Components = map[string]types.ComponentHealth{}
+	var err error
+	if !a.sender.alive() {
+		err = errors.New("worker is not running")
+	}
+	ret.Components["sender"] = componentHealth(err)
+
+	if ready {
+		ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+		defer cancel()
+
+		var lock sync.Mutex
+		wg := &sync.WaitGroup{}
+		check := func(name string, f func(context.Context) error) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				x := componentHealth(f(ctx))
+				lock.Lock()
+				defer lock.Unlock()
+				ret.Components[name] = x
+			}()
+		}
+
+		check("db", a.Ping)
+		for _, typ := range a.sender.drivers() {
+			drv, _ := a.sender.driver(typ)
+			if h, ok := drv.(types.HealthChecker); ok {
+				check("driver:"+typ, h.Health)
+			}
+		}
+		wg.Wait()
+	}
+
+	ret.OK = true
+	for name, c := range ret.Components {
+		if !c.OK {
+			ret.OK = false
+			a.log.Warn("unhealthy component", "component", name, "error", c.Error)
+		}
+	}
+	return
+}
@@ -0,0 +1,60 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// Hooks defines callbacks on lifecycle events of notifications, nil ones are
+// skipped. They are called synchronously by api handlers or sender threads, so
+// they *SHOULD* return as soon as possible, and *MUST NOT* modify the item.
+type Hooks struct {
+	// called after a notification is saved by /send or /sendOnce
+	OnCreated func(i *model.Item)
+	// called after every try, i.Tried is updated
+	OnAttempt func(i *model.Item, resp []byte, err error)
+	// called after a notification is delivered
+	OnSucceeded func(i *model.Item)
+	// called after a notification is given up, i.State is either
+	// types.FAILED or types.EXPIRED
+	OnFailed func(i *model.Item)
+	// called after a notification is deleted by /delete. Content of i is
+	// fetched before deleting. Not called by /clear and /forceClear.
+	OnDeleted func(i *model.Item)
+}
+
+func (h *Hooks) created(i *model.Item) {
+	if h.OnCreated != nil {
+		h.OnCreated(i)
+	}
+}
+
+func (h *Hooks) attempted(i *model.Item, resp []byte, err error) {
+	if h.OnAttempt != nil {
+		h.OnAttempt(i, resp, err)
+	}
+}
+
+// finished calls OnSucceeded or OnFailed according to i.State
+func (h *Hooks) finished(i *model.Item) {
+	switch i.State {
+	case types.SUCCESS:
+		if h.OnSucceeded != nil {
+			h.OnSucceeded(i)
+		}
+	case types.FAILED, types.EXPIRED:
+		if h.OnFailed != nil {
+			h.OnFailed(i)
+		}
+	}
+}
+
+func (h *Hooks) deleted(i *model.Item) {
+	if h.OnDeleted != nil {
+		h.OnDeleted(i)
+	}
+}
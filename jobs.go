@@ -19,10 +19,6 @@ func newJobCtl(threads uint16) (ret *jobCtrl) {
 	}
 }
 
-func (j *jobCtrl) set(tid uint16, jid string) {
-	j.jobIDs[tid] = jid
-}
-
 func (j *jobCtrl) lset(tid uint16, jid string) {
 	j.Lock()
 	defer j.Unlock()
@@ -37,8 +33,3 @@ func (j *jobCtrl) list() (ret []string) {
 	ret = append(ret, j.jobIDs...)
 	return
 }
-
-func (j *jobCtrl) rawList() (ret []string) {
-	ret = append(ret, j.jobIDs...)
-	return
-}
@@ -0,0 +1,219 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// journalEntry is a result which cannot be written to db, see DBDrv.Update
+type journalEntry struct {
+	ID    string      `json:"id"`
+	Lease string      `json:"lease"`
+	Tried uint32      `json:"tried"`
+	Next  int64       `json:"next"`
+	State types.State `json:"state"`
+	Resp  []byte      `json:"resp"`
+}
+
+// journal is an append-only file of results, one json encoded journalEntry
+// per line. nil disables it.
+type journal struct {
+	path  string
+	dirty int32 // 1 if there might be entries to replay, accessed atomically
+	sync.Mutex
+}
+
+func newJournal(path string) (ret *journal) {
+	ret = &journal{path: path}
+	if _, err := os.Stat(path); err == nil {
+		ret.dirty = 1
+	}
+	return
+}
+
+func (j *journal) append(e journalEntry) (err error) {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	buf = append(buf, '\n')
+
+	j.Lock()
+	defer j.Unlock()
+
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		atomic.StoreInt32(&j.dirty, 1)
+	}
+	return
+}
+
+func (j *journal) needReplay() (ret bool) {
+	return j != nil && atomic.LoadInt32(&j.dirty) == 1
+}
+
+// read reads all entries, broken lines (written partially when crashed) are
+// skipped
+func (j *journal) read() (ret []journalEntry, err error) {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64*1024*1024)
+	for s.Scan() {
+		var e journalEntry
+		if json.Unmarshal(s.Bytes(), &e) == nil && e.ID != "" {
+			ret = append(ret, e)
+		}
+	}
+	err = s.Err()
+	return
+}
+
+// rewrite replaces the journal with entries atomically
+func (j *journal) rewrite(entries []journalEntry) (err error) {
+	if len(entries) == 0 {
+		err = os.Remove(j.path)
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+	if err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err = enc.Encode(e); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	return os.Rename(tmp, j.path)
+}
+
+// replay writes journaled results to db, and keeps those still failing in
+// the journal. Results are applied only if the notification is still leased by
+// the try, stale ones are dropped.
+func (j *journal) replay(db model.DBDrv, log Logger) (err error) {
+	j.Lock()
+	defer j.Unlock()
+
+	entries, err := j.read()
+	if err != nil {
+		return
+	}
+
+	failed := make([]journalEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.Lease == "" {
+			// written by older version, cannot tell if it is stale
+			log.Warn("skipped stale result", "id", e.ID, "tried", e.Tried, "state", e.State)
+			continue
+		}
+		err := db.Update(e.ID, e.Lease, e.Tried, e.Next, e.State, e.Resp)
+		if _, ok := err.(*model.ELostLease); ok {
+			// resent, requeued or claimed by others after lease expired
+			log.Warn("skipped stale result", "id", e.ID, "tried", e.Tried, "state", e.State)
+			continue
+		}
+		if err != nil {
+			failed = append(failed, e)
+			continue
+		}
+		log.Info("replayed result", "id", e.ID, "tried", e.Tried, "state", e.State)
+	}
+
+	if err = j.rewrite(failed); err != nil {
+		return
+	}
+	if len(failed) == 0 {
+		atomic.StoreInt32(&j.dirty, 0)
+	}
+	return
+}
+
+// update writes result of i to db, retries with backoff if failed and then
+// journals it, so a delivered notification will not be sent again.
+func (t *thread) update(i *model.Item, state types.State, resp []byte) {
+	wait := t.UpdateBackoff
+	var err error
+	for x := uint32(0); ; x++ {
+		err = t.Update(i.ID, i.Lease, i.Tried, i.NextAt, state, resp)
+		if err == nil {
+			return
+		}
+		if _, ok := err.(*model.ELostLease); ok {
+			// claimed by others after lease expired, the result is stale
+			t.Logger.Warn("lost lease, result is discarded", itemArgs(i, "state", state)...)
+			return
+		}
+		if x >= t.UpdateRetries {
+			break
+		}
+		t.Logger.Warn("cannot update notification, retrying", itemArgs(i, "state", state, "wait", wait, "error", err)...)
+		time.Sleep(wait)
+		wait *= 2
+	}
+
+	t.Logger.Error("cannot update notification", itemArgs(i, "state", state, "error", err)...)
+	if t.journal == nil {
+		return
+	}
+	err = t.journal.append(journalEntry{
+		ID:    i.ID,
+		Lease: i.Lease,
+		Tried: i.Tried,
+		Next:  i.NextAt,
+		State: state,
+		Resp:  resp,
+	})
+	if err != nil {
+		t.Logger.Error("cannot journal result", itemArgs(i, "state", state, "error", err)...)
+		return
+	}
+	t.Logger.Warn("journaled result", itemArgs(i, "state", state, "path", t.journal.path)...)
+}
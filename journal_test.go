@@ -0,0 +1,73 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// leaseDB accepts Update only if lease matches, and fails if lease is "broken"
+type leaseDB struct {
+	model.DBDrv
+	lease   string
+	updated []string
+}
+
+func (d *leaseDB) Update(id, lease string, tried uint32, next int64, state types.State, resp []byte) (err error) {
+	switch lease {
+	case d.lease:
+		d.updated = append(d.updated, id)
+		return nil
+	case "broken":
+		return errors.New("db is broken")
+	}
+	return &model.ELostLease{}
+}
+
+func TestJournalReplayStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify-journal")
+	if err != nil {
+		t.Fatal("cannot create temp dir: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	j := newJournal(filepath.Join(dir, "journal"))
+	for _, e := range []journalEntry{
+		{ID: "ok", Lease: "node/1", State: types.SUCCESS},
+		{ID: "stale", Lease: "node/0", State: types.SUCCESS},
+		{ID: "old", State: types.SUCCESS},
+		{ID: "broken", Lease: "broken", State: types.SUCCESS},
+	} {
+		if err = j.append(e); err != nil {
+			t.Fatal("cannot append: ", err)
+		}
+	}
+
+	db := &leaseDB{lease: "node/1"}
+	if err = j.replay(db, nopLogger{}); err != nil {
+		t.Fatal("cannot replay: ", err)
+	}
+	if len(db.updated) != 1 || db.updated[0] != "ok" {
+		t.Errorf("expected only ok to be applied, got %v", db.updated)
+	}
+
+	entries, err := j.read()
+	if err != nil {
+		t.Fatal("cannot read journal: ", err)
+	}
+	if len(entries) != 1 || entries[0].ID != "broken" {
+		t.Errorf("expected only broken to be kept, got %+v", entries)
+	}
+	if !j.needReplay() {
+		t.Error("expected journal to be replayed again")
+	}
+}
@@ -0,0 +1,62 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger defines structured logger used by sender and api server. args are
+// alternating keys and values, so *slog.Logger can be used directly.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// StdLogger creates a Logger writes to l in "LEVEL msg key=value ..." format.
+// Debug messages are dropped unless debug is true.
+func StdLogger(l *log.Logger, debug bool) (ret Logger) {
+	return &stdLogger{l: l, debug: debug}
+}
+
+type stdLogger struct {
+	l     *log.Logger
+	debug bool
+}
+
+func (s *stdLogger) print(level, msg string, args []interface{}) {
+	b := &strings.Builder{}
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			fmt.Fprintf(b, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(b, " %v=%q", args[i], fmt.Sprint(args[i+1]))
+	}
+	s.l.Print(b.String())
+}
+
+func (s *stdLogger) Debug(msg string, args ...interface{}) {
+	if s.debug {
+		s.print("DEBUG", msg, args)
+	}
+}
+func (s *stdLogger) Info(msg string, args ...interface{})  { s.print("INFO", msg, args) }
+func (s *stdLogger) Warn(msg string, args ...interface{})  { s.print("WARN", msg, args) }
+func (s *stdLogger) Error(msg string, args ...interface{}) { s.print("ERROR", msg, args) }
@@ -0,0 +1,156 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// upper bounds (in seconds) of latency histogram buckets
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type histogram struct {
+	buckets []uint64 // not cumulative
+	sum     float64
+	count   uint64
+}
+
+type sendKey struct {
+	driver  string
+	outcome string
+}
+
+// metrics collects sending statistics, nil disables it
+type metrics struct {
+	sends   map[sendKey]uint64
+	latency map[string]*histogram
+	sync.Mutex
+}
+
+func newMetrics() (ret *metrics) {
+	return &metrics{
+		sends:   map[sendKey]uint64{},
+		latency: map[string]*histogram{},
+	}
+}
+
+func outcome(err error) (ret string) {
+	switch {
+	case err == nil:
+		return "success"
+	case types.IsPermanent(err):
+		return "permanent"
+	}
+	return "error"
+}
+
+// observe records a try of driver which takes d
+func (m *metrics) observe(driver string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	m.sends[sendKey{driver: driver, outcome: outcome(err)}]++
+
+	h, ok := m.latency[driver]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(latencyBuckets))}
+		m.latency[driver] = h
+	}
+	sec := d.Seconds()
+	for idx, b := range latencyBuckets {
+		if sec <= b {
+			h.buckets[idx]++
+			break
+		}
+	}
+	h.sum += sec
+	h.count++
+}
+
+// write writes collected metrics in prometheus text format
+func (m *metrics) write(w io.Writer) {
+	m.Lock()
+	defer m.Unlock()
+
+	keys := make([]sendKey, 0, len(m.sends))
+	for k := range m.sends {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].driver != keys[j].driver {
+			return keys[i].driver < keys[j].driver
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+	fmt.Fprintln(w, "# HELP notify_sends_total Number of tries by driver and outcome.")
+	fmt.Fprintln(w, "# TYPE notify_sends_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "notify_sends_total{driver=%q,outcome=%q} %d\n", k.driver, k.outcome, m.sends[k])
+	}
+
+	drvs := make([]string, 0, len(m.latency))
+	for k := range m.latency {
+		drvs = append(drvs, k)
+	}
+	sort.Strings(drvs)
+	fmt.Fprintln(w, "# HELP notify_send_duration_seconds Time spent in Driver.Send.")
+	fmt.Fprintln(w, "# TYPE notify_send_duration_seconds histogram")
+	for _, drv := range drvs {
+		h := m.latency[drv]
+		var cnt uint64
+		for idx, b := range latencyBuckets {
+			cnt += h.buckets[idx]
+			fmt.Fprintf(w, "notify_send_duration_seconds_bucket{driver=%q,le=\"%g\"} %d\n", drv, b, cnt)
+		}
+		fmt.Fprintf(w, "notify_send_duration_seconds_bucket{driver=%q,le=\"+Inf\"} %d\n", drv, h.count)
+		fmt.Fprintf(w, "notify_send_duration_seconds_sum{driver=%q} %g\n", drv, h.sum)
+		fmt.Fprintf(w, "notify_send_duration_seconds_count{driver=%q} %d\n", drv, h.count)
+	}
+}
+
+// writeThreads writes usage of sending threads in prometheus text format
+func writeThreads(w io.Writer, jobs []string) {
+	busy := 0
+	for _, id := range jobs {
+		if id != "" {
+			busy++
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP notify_threads Number of sending threads.")
+	fmt.Fprintln(w, "# TYPE notify_threads gauge")
+	fmt.Fprintf(w, "notify_threads %d\n", len(jobs))
+	fmt.Fprintln(w, "# HELP notify_threads_busy Number of threads which are sending.")
+	fmt.Fprintln(w, "# TYPE notify_threads_busy gauge")
+	fmt.Fprintf(w, "notify_threads_busy %d\n", busy)
+}
+
+// writeCounts writes number of notifications in db in prometheus text format
+func writeCounts(w io.Writer, counts []model.Count) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Driver != counts[j].Driver {
+			return counts[i].Driver < counts[j].Driver
+		}
+		return counts[i].State < counts[j].State
+	})
+
+	fmt.Fprintln(w, "# HELP notify_notifications Number of notifications in db by driver and state.")
+	fmt.Fprintln(w, "# TYPE notify_notifications gauge")
+	for _, c := range counts {
+		st := strings.ToLower(c.State.String())
+		fmt.Fprintf(w, "notify_notifications{driver=%q,state=%q} %d\n", c.Driver, st, c.Count)
+	}
+}
@@ -5,6 +5,7 @@
 package model
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log"
@@ -56,6 +57,12 @@ func (d *DrvBase) Prepare(qstr string, e error) (err error) {
 	return
 }
 
+// Ping checks db connection, so DBDrv embedding DrvBase does not need to
+// implement it.
+func (d *DrvBase) Ping(ctx context.Context) (err error) {
+	return d.DB.PingContext(ctx)
+}
+
 // Stmt retrieves previously cached *sql.Stmt, returns nil if not found
 //
 // It is *RECOMMENDED* to store your sql query statement in constant or variable,
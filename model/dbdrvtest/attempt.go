@@ -0,0 +1,79 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testAttempts ensures every try is recorded, and removed with notification
+func (s *suite) testAttempts(t *testing.T) {
+	var cnt int32
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if atomic.AddInt32(&cnt, 1) < 3 {
+			return []byte("fail"), errors.New("err")
+		}
+		return []byte("ok"), nil
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	err := s.cl.Send("attempt", drvType, "attempt", map[string][]string{}, types.WithRetry(types.RetryPolicy{
+		Backoff: types.BackoffFixed,
+		Base:    1,
+	}))
+	if err != nil {
+		t.Fatal("cannot create notify: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for {
+		st, err := s.cl.Status("attempt")
+		if err != nil {
+			t.Fatal("cannot get status: ", err)
+		}
+		if st.State == types.SUCCESS {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("notification is not sent in 10 seconds: %+v", st)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	arr, err := s.cl.Attempts("attempt")
+	if err != nil {
+		t.Fatal("cannot get attempts: ", err)
+	}
+	if len(arr) != 3 {
+		t.Fatalf("expected 3 attempts, got %+v", arr)
+	}
+	for idx, a := range arr {
+		failed := idx < 2
+		if a.Tried != uint32(idx+1) || (a.Error != "") != failed || a.Thread == "" {
+			t.Errorf("unexpected attempt #%d: %+v", idx, a)
+		}
+		if expect := map[bool]string{true: "fail", false: "ok"}[failed]; string(a.Response) != expect {
+			t.Errorf("expected response of attempt #%d to be %s, got %s", idx, expect, a.Response)
+		}
+	}
+
+	if err = s.cl.Delete("attempt"); err != nil {
+		t.Fatal("cannot delete notify: ", err)
+	}
+	arr, err = s.dbdrv.Attempts("attempt")
+	if err != nil || len(arr) != 0 {
+		t.Errorf("expected attempts to be deleted, got %+v (%v)", arr, err)
+	}
+}
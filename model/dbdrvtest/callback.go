@@ -0,0 +1,98 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testCallback ensures final status is posted to callback url
+func (s *suite) testCallback(t *testing.T) {
+	ch := make(chan types.CallbackBody, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b types.CallbackBody
+		if r.Header.Get("X-Test") != "yes" {
+			t.Errorf("missing header: %+v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+			t.Errorf("cannot decode callback: %v", err)
+		}
+		ch <- b
+	}))
+	defer srv.Close()
+
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if ep == "ok" {
+			return []byte(ep), nil
+		}
+		return []byte(ep), errors.New("err")
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	// callback driver is internal
+	if err := s.cl.SendOnce("cb0", "CALLBACK", srv.URL, map[string]interface{}{}); err == nil {
+		t.Error("expected sending with callback driver to fail")
+	}
+
+	cb := types.WithCallback(types.Callback{
+		URL:     srv.URL,
+		Headers: http.Header{"X-Test": []string{"yes"}},
+	})
+	if err := s.cl.SendOnce("cb1", drvType, "ok", map[string][]string{}, cb); err != nil {
+		t.Fatal("cannot create cb1: ", err)
+	}
+	if err := s.cl.SendOnce("cb2", drvType, "fail", map[string][]string{}, cb); err != nil {
+		t.Fatal("cannot create cb2: ", err)
+	}
+
+	got := map[string]types.CallbackBody{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case b := <-ch:
+			got[b.ID] = b
+		case <-timeout:
+			t.Fatalf("callbacks are not sent in 5 seconds: %+v", got)
+		}
+	}
+
+	if b := got["cb1"]; b.State != types.SUCCESS || string(b.Response) != "ok" || b.Tried != 1 {
+		t.Errorf("unexpected callback of cb1: %+v", b)
+	}
+	if b := got["cb2"]; b.State != types.FAILED || string(b.Response) != "fail" || b.Tried != 1 {
+		t.Errorf("unexpected callback of cb2: %+v", b)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	st, err := s.cl.Status("callback:cb1#1:SUCCESS")
+	if err != nil {
+		t.Fatal("cannot get status of callback: ", err)
+	}
+	if st.State != types.SUCCESS {
+		t.Errorf("expected callback to be SUCCESS, got %+v", st)
+	}
+
+	// resent notification calls back again
+	if err = s.cl.Resend("cb2"); err != nil {
+		t.Fatal("cannot resend cb2: ", err)
+	}
+	select {
+	case b := <-ch:
+		if b.ID != "cb2" || b.State != types.FAILED || b.Tried != 2 {
+			t.Errorf("unexpected callback of resent cb2: %+v", b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback of resent cb2 is not sent in 5 seconds")
+	}
+}
@@ -6,6 +6,7 @@ package dbdrvtest
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 )
@@ -39,9 +40,13 @@ func (s *suite) testDelete(t *testing.T) {
 }
 
 func (s *suite) testDeleteBefore(t *testing.T) {
-	if err := s.cl.Delete("delete"); err == nil {
+	err := s.cl.Delete("delete")
+	if err == nil {
 		t.Fatal("deleting running task should be error, but got nothing")
 	}
+	if !strings.HasSuffix(err.Error(), ": 409") {
+		t.Errorf("expected 409 when deleting running task, got %v", err)
+	}
 
 	if _, err := s.cl.Status("delete"); err != nil {
 		t.Fatal("cannot get running task: ", err)
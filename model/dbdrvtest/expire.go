@@ -0,0 +1,73 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testExpire ensures notifications past deadline are marked as EXPIRED
+func (s *suite) testExpire(t *testing.T) {
+	var lock sync.Mutex
+	cnt := map[string]int{}
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		lock.Lock()
+		cnt[ep]++
+		lock.Unlock()
+		return []byte(ep), errors.New("err")
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	// retries every second, so it expires after 2 tries
+	err := s.cl.Send("expire1", drvType, "expire1", map[string][]string{}, types.WithTTL(2*time.Second))
+	if err != nil {
+		t.Fatal("cannot create expire1: ", err)
+	}
+	// expired before first try, never sent
+	past := time.Now().Add(-time.Minute)
+	err = s.cl.Send("expire2", drvType, "expire2", map[string][]string{}, types.WithExpireAt(past))
+	if err != nil {
+		t.Fatal("cannot create expire2: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, id := range []string{"expire1", "expire2"} {
+		for {
+			st, err := s.cl.Status(id)
+			if err != nil {
+				t.Fatalf("cannot get status of %s: %s", id, err)
+			}
+			if st.State == types.EXPIRED {
+				break
+			}
+			if st.State != types.PENDING {
+				t.Fatalf("unexpected status of %s: %+v", id, st)
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("%s is not expired in 10 seconds", id)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if c := cnt["expire1"]; c != 2 {
+		t.Errorf("expected expire1 to be sent 2 times, got %d", c)
+	}
+	if c := cnt["expire2"]; c != 0 {
+		t.Errorf("expected expire2 not to be sent, got %d times", c)
+	}
+}
@@ -0,0 +1,87 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testFailed ensures FAILED notifications can be listed and requeued
+func (s *suite) testFailed(t *testing.T) {
+	var ok int32
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if atomic.LoadInt32(&ok) == 1 {
+			return []byte("ok"), nil
+		}
+		return []byte("fail"), errors.New("err")
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	begin := types.Timestamp(time.Now().Unix())
+	for _, x := range [][2]string{
+		{"dead1", "deadA"},
+		{"dead2", "deadB"},
+		{"dead3", "deadA"},
+	} {
+		if err := s.sendOnce(x[0], x[1]); err != nil {
+			t.Fatalf("cannot create %s: %v", x[0], err)
+		}
+	}
+	time.Sleep(time.Second)
+
+	arr, err := s.cl.Failed(types.Filter{Driver: drvType, Endpoint: "deadA", After: begin})
+	if err != nil {
+		t.Fatal("cannot list failed: ", err)
+	}
+	if len(arr) != 2 || arr[0].ID == arr[1].ID {
+		t.Fatalf("expected dead1 and dead3, got %+v", arr)
+	}
+	for _, x := range arr {
+		if (x.ID != "dead1" && x.ID != "dead3") || x.State != types.FAILED || string(x.Response) != "fail" {
+			t.Errorf("unexpected failed notification: %+v", x)
+		}
+	}
+
+	arr, err = s.cl.Failed(types.Filter{Endpoint: "deadA", Before: begin})
+	if err != nil || len(arr) != 0 {
+		t.Errorf("expected nothing before test begins, got %+v (%v)", arr, err)
+	}
+
+	if _, err = s.cl.Requeue(types.Filter{}); err == nil {
+		t.Error("expected requeueing with empty filter to fail")
+	}
+
+	atomic.StoreInt32(&ok, 1)
+	cnt, err := s.cl.Requeue(types.Filter{Endpoint: "deadA", After: begin})
+	if err != nil {
+		t.Fatal("cannot requeue: ", err)
+	}
+	if cnt != 2 {
+		t.Errorf("expected 2 notifications requeued, got %d", cnt)
+	}
+	time.Sleep(time.Second)
+
+	// tried is kept after requeueing
+	for id, expect := range map[string]types.Status{
+		"dead1": {State: types.SUCCESS, Tried: 2},
+		"dead2": {State: types.FAILED, Tried: 1},
+		"dead3": {State: types.SUCCESS, Tried: 2},
+	} {
+		st, err := s.cl.Status(id)
+		if err != nil {
+			t.Fatalf("cannot get status of %s: %v", id, err)
+		}
+		if st.State != expect.State || st.Tried != expect.Tried {
+			t.Errorf("unexpected status of %s: %+v", id, st)
+		}
+	}
+}
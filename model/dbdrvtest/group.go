@@ -0,0 +1,65 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testGroup ensures /broadcast creates a notification for each target, and
+// /groupStatus aggregates their states
+func (s *suite) testGroup(t *testing.T) {
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if ep == "fail" {
+			return nil, types.Permanent(errors.New(ep))
+		}
+		return []byte(ep), nil
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	targets := []types.Target{
+		{Driver: drvType, Endpoint: "ok", Payload: []byte(`1`)},
+		{Driver: drvType, Endpoint: "fail", Payload: []byte(`2`)},
+	}
+	ids, err := s.cl.Broadcast("group", targets, types.WithRetry(types.RetryPolicy{MaxTries: 1}))
+	if err != nil {
+		t.Fatal("cannot broadcast: ", err)
+	}
+	if len(ids) != 2 || ids[0] != "group#000" || ids[1] != "group#001" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	// replaying is fine, but changing target is not
+	if _, err = s.cl.Broadcast("group", targets, types.WithRetry(types.RetryPolicy{MaxTries: 1})); err != nil {
+		t.Fatal("cannot replay broadcast: ", err)
+	}
+	targets[1].Endpoint = "ok"
+	_, err = s.cl.Broadcast("group", targets)
+	if e, ok := err.(*types.ConflictError); !ok || e.ID != "group#001" {
+		t.Fatalf("expected conflict of group#001, got %v", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	st, err := s.cl.GroupStatus("group")
+	if err != nil {
+		t.Fatal("cannot get group status: ", err)
+	}
+	if st.State != types.GroupPartial || st.Success != 1 || st.Failed != 1 || len(st.Members) != 2 {
+		t.Fatalf("unexpected group status: %+v", st)
+	}
+	if m := st.Members[1]; m.ID != "group#001" || m.Endpoint != "fail" || m.State != types.FAILED {
+		t.Errorf("unexpected member: %+v", m)
+	}
+
+	if _, err = s.cl.GroupStatus("no-such-group"); err == nil {
+		t.Error("expected error for unknown group")
+	}
+}
@@ -0,0 +1,45 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testHealth ensures /readyz checks db and worker
+func (s *suite) testHealth(t *testing.T) {
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		return []byte(ep), nil
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	get := func(path string) (code int, ret types.Health) {
+		resp, err := http.Get("http://" + s.bind + path)
+		if err != nil {
+			t.Fatalf("cannot get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if err = json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+			t.Fatalf("cannot decode %s: %v", path, err)
+		}
+		return resp.StatusCode, ret
+	}
+
+	code, h := get("/healthz")
+	if code != 200 || !h.OK || !h.Components["sender"].OK || len(h.Components) != 1 {
+		t.Errorf("unexpected health (%d): %+v", code, h)
+	}
+
+	code, h = get("/readyz")
+	if code != 200 || !h.OK || !h.Components["db"].OK || !h.Components["sender"].OK {
+		t.Errorf("unexpected readiness (%d): %+v", code, h)
+	}
+}
@@ -0,0 +1,93 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify"
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// testHooks ensures hooks are called on lifecycle events
+func (s *suite) testHooks(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		deleted *model.Item
+	)
+	events := []string{}
+	add := func(ev string) func(*model.Item) {
+		return func(i *model.Item) {
+			lock.Lock()
+			defer lock.Unlock()
+			events = append(events, ev+":"+i.ID)
+		}
+	}
+	hooks := notify.Hooks{
+		OnCreated: add("created"),
+		OnAttempt: func(i *model.Item, resp []byte, err error) {
+			add("attempt")(i)
+		},
+		OnSucceeded: add("succeeded"),
+		OnFailed:    add("failed"),
+		OnDeleted: func(i *model.Item) {
+			add("deleted")(i)
+			lock.Lock()
+			deleted = i
+			lock.Unlock()
+		},
+	}
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if ep == "ok" {
+			return []byte(ep), nil
+		}
+		return []byte(ep), errors.New("err")
+	}
+	api := s.start(f, func(o *notify.SenderOptions) { o.Hooks = hooks })
+	defer api.Shutdown(context.Background())
+
+	if err := s.sendOnce("hook1", "ok"); err != nil {
+		t.Fatal("cannot create hook1: ", err)
+	}
+	if err := s.sendOnce("hook2", "fail"); err != nil {
+		t.Fatal("cannot create hook2: ", err)
+	}
+	time.Sleep(time.Second)
+	if err := s.cl.Delete("hook1"); err != nil {
+		t.Fatal("cannot delete hook1: ", err)
+	}
+	// hook does not change response of deleting unknown id
+	if err := s.cl.Delete("hook-unknown"); err != nil {
+		t.Error("unexpected error deleting unknown id: ", err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	got := map[string]bool{}
+	for _, ev := range events {
+		got[ev] = true
+	}
+	expect := []string{
+		"created:hook1", "attempt:hook1", "succeeded:hook1", "deleted:hook1",
+		"created:hook2", "attempt:hook2", "failed:hook2",
+	}
+	for _, ev := range expect {
+		if !got[ev] {
+			t.Errorf("missing event %s, got %v", ev, events)
+		}
+	}
+	if len(events) != len(expect) {
+		t.Errorf("expected %d events, got %v", len(expect), events)
+	}
+	// every stored field is passed to OnDeleted
+	if deleted == nil || deleted.Endpoint != "ok" || deleted.Retry.MaxTries != 1 || deleted.State != types.SUCCESS {
+		t.Errorf("unexpected deleted item: %+v", deleted)
+	}
+}
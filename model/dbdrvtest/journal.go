@@ -0,0 +1,90 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify"
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// brokenUpdate fails Update when broken is 1
+type brokenUpdate struct {
+	model.DBDrv
+	broken int32
+}
+
+func (b *brokenUpdate) Update(id, lease string, tried uint32, next int64, state types.State, resp []byte) (err error) {
+	if atomic.LoadInt32(&b.broken) == 1 {
+		return errors.New("db is broken")
+	}
+	return b.DBDrv.Update(id, lease, tried, next, state, resp)
+}
+
+// testJournal ensures results are journaled and replayed if db is unavailable
+func (s *suite) testJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify-journal")
+	if err != nil {
+		t.Fatal("cannot create temp dir: ", err)
+	}
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "journal")
+
+	var cnt int32
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		atomic.AddInt32(&cnt, 1)
+		return []byte(ep), nil
+	}
+	db := &brokenUpdate{DBDrv: s.dbdrv, broken: 1}
+	api := s.start(f, func(o *notify.SenderOptions) {
+		o.DBDrv = db
+		o.UpdateRetries = 1
+		o.UpdateBackoff = 10 * time.Millisecond
+		o.Journal = fn
+	})
+	defer api.Shutdown(context.Background())
+
+	if err := s.send("journal", "ok"); err != nil {
+		t.Fatal("cannot create notify: ", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	if _, err := os.Stat(fn); err != nil {
+		t.Fatal("expected result to be journaled: ", err)
+	}
+	st, err := s.cl.Status("journal")
+	if err != nil {
+		t.Fatal("cannot get status: ", err)
+	}
+	if st.State != types.PENDING {
+		t.Fatalf("expected PENDING before replaying, got %+v", st)
+	}
+
+	atomic.StoreInt32(&db.broken, 0)
+	time.Sleep(time.Second)
+
+	st, err = s.cl.Status("journal")
+	if err != nil {
+		t.Fatal("cannot get status: ", err)
+	}
+	if st.State != types.SUCCESS || st.Tried != 1 {
+		t.Errorf("expected SUCCESS after replaying, got %+v", st)
+	}
+	if x := atomic.LoadInt32(&cnt); x != 1 {
+		t.Errorf("expected to be sent once, got %d", x)
+	}
+	if _, err := os.Stat(fn); !os.IsNotExist(err) {
+		t.Errorf("expected journal to be removed, got %v", err)
+	}
+}
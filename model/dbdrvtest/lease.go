@@ -0,0 +1,134 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// testLease runs two instances with same db, every notification should be sent
+// exactly once
+func (s *suite) testLease(t *testing.T) {
+	const total = 10
+	var lock sync.Mutex
+	cnt := map[string]int{}
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		lock.Lock()
+		cnt[ep]++
+		lock.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		return []byte(ep), nil
+	}
+	api1 := s.start(f)
+	defer api1.Shutdown(context.Background())
+	api2 := s.create(f)
+	api2.GetHTTPServer().Addr = "127.0.0.1:0"
+	go api2.Start()
+	defer api2.Shutdown(context.Background())
+
+	for i := 0; i < total; i++ {
+		id := "lease" + strconv.Itoa(i)
+		if err := s.send(id, id); err != nil {
+			t.Fatal("cannot create notify: ", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			t.Fatal("notifications are not sent in 10 seconds")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		lock.Lock()
+		done = len(cnt) == total
+		lock.Unlock()
+	}
+	time.Sleep(2 * time.Second)
+
+	lock.Lock()
+	defer lock.Unlock()
+	for id, c := range cnt {
+		if c != 1 {
+			t.Errorf("%s is sent %d times", id, c)
+		}
+	}
+}
+
+// testStaleLease ensures a sender cannot update the notification after its
+// lease expired and the notification is claimed by others
+func (s *suite) testStaleLease(t *testing.T) {
+	const typ = "STALE"
+	now := time.Now().Unix()
+	err := s.dbdrv.Create(&model.Item{
+		ID:       "stale",
+		Driver:   typ,
+		Endpoint: "stale",
+		Content:  []byte(`{}`),
+		CreateAt: now,
+		NextAt:   now,
+	})
+	if err != nil {
+		t.Fatal("cannot create notification: ", err)
+	}
+
+	// lease of stale expires immediately
+	stale, err := s.dbdrv.Pending(now, 3, []string{typ}, "stale", now)
+	if err != nil || stale == nil {
+		t.Fatalf("cannot claim by stale owner: %v", err)
+	}
+	fresh, err := s.dbdrv.Pending(now, 3, []string{typ}, "fresh", now+60)
+	if err != nil || fresh == nil {
+		t.Fatalf("cannot claim by fresh owner: %v", err)
+	}
+	if fresh.Lease == stale.Lease {
+		t.Fatalf("expected different lease tokens, got %s", fresh.Lease)
+	}
+
+	lost := func(name string, err error) {
+		if _, ok := err.(*model.ELostLease); !ok {
+			t.Errorf("expected %s by stale owner to lose lease, got %v", name, err)
+		}
+	}
+	lost("Update", s.dbdrv.Update(stale.ID, stale.Lease, 1, now, types.FAILED, []byte("stale")))
+	lost("Postpone", s.dbdrv.Postpone(stale.ID, stale.Lease, now))
+	lost("Expire", s.dbdrv.Expire(stale.ID, stale.Lease, now))
+
+	st, err := s.dbdrv.Status(stale.ID)
+	if err != nil {
+		t.Fatal("cannot get status: ", err)
+	}
+	if st.State != types.PENDING || st.Tried != 0 {
+		t.Fatalf("notification is modified by stale owner: %+v", st)
+	}
+
+	if _, ok := s.dbdrv.Resend(fresh.ID).(*model.E409); !ok {
+		t.Error("expected resending leased notification to conflict")
+	}
+
+	if err = s.dbdrv.Update(fresh.ID, fresh.Lease, 1, now, types.SUCCESS, []byte("fresh")); err != nil {
+		t.Fatal("cannot update by fresh owner: ", err)
+	}
+	resp, err := s.dbdrv.Result(fresh.ID)
+	if err != nil || string(resp) != "fresh" {
+		t.Errorf("unexpected result: %s (%v)", resp, err)
+	}
+	lost("second Update", s.dbdrv.Update(fresh.ID, fresh.Lease, 2, now, types.FAILED, nil))
+
+	// journaled result of fresh owner cannot overwrite resending
+	if err = s.dbdrv.Resend(fresh.ID); err != nil {
+		t.Fatal("cannot resend: ", err)
+	}
+	lost("Update after resending", s.dbdrv.Update(fresh.ID, fresh.Lease, 1, now, types.SUCCESS, nil))
+}
@@ -0,0 +1,54 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify"
+)
+
+// testMetrics ensures /metrics reports tries and db counts
+func (s *suite) testMetrics(t *testing.T) {
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		return []byte(ep), nil
+	}
+	api := s.start(f, func(o *notify.SenderOptions) { o.Metrics = true })
+	defer api.Shutdown(context.Background())
+
+	if err := s.sendOnce("metrics", "ok"); err != nil {
+		t.Fatal("cannot create notify: ", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	resp, err := http.Get("http://" + s.bind + "/metrics")
+	if err != nil {
+		t.Fatal("cannot get metrics: ", err)
+	}
+	defer resp.Body.Close()
+	buf, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("unexpected status code %d: %s", resp.StatusCode, buf)
+	}
+
+	expect := []string{
+		`notify_sends_total{driver="TEST",outcome="success"} 1` + "\n",
+		`notify_send_duration_seconds_count{driver="TEST"} 1` + "\n",
+		fmt.Sprintf("notify_threads %d\n", MaxThread),
+		`notify_threads_busy 0` + "\n",
+		`notify_notifications{driver="TEST",state="success"} `,
+	}
+	for _, e := range expect {
+		if !strings.Contains(string(buf), e) {
+			t.Errorf("expected %q in metrics, got %s", e, buf)
+		}
+	}
+}
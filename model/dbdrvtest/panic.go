@@ -0,0 +1,71 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify"
+	"github.com/raohwork/notify/types"
+)
+
+// testPanic ensures panics of driver are recorded, and the driver is
+// quarantined if it keeps panicking
+func (s *suite) testPanic(t *testing.T) {
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if ep == "panic" {
+			panic("boom")
+		}
+		return []byte(ep), nil
+	}
+	api := s.start(f, func(o *notify.SenderOptions) { o.QuarantineThreshold = 2 })
+	defer api.Shutdown(context.Background())
+
+	for _, id := range []string{"panic1", "panic2"} {
+		if err := s.sendOnce(id, "panic"); err != nil {
+			t.Fatalf("cannot create %s: %v", id, err)
+		}
+		time.Sleep(300 * time.Millisecond)
+
+		arr, err := s.cl.Attempts(id)
+		if err != nil {
+			t.Fatalf("cannot get attempts of %s: %v", id, err)
+		}
+		if len(arr) != 1 || !strings.Contains(arr[0].Error, "boom") || !strings.HasPrefix(string(arr[0].Response), "panic: boom") {
+			t.Fatalf("unexpected attempts of %s: %+v", id, arr)
+		}
+		st, err := s.cl.Status(id)
+		if err != nil || st.State != types.FAILED {
+			t.Fatalf("expected %s to be FAILED, got %+v (%v)", id, st, err)
+		}
+	}
+
+	p, err := s.cl.Paused()
+	if err != nil {
+		t.Fatal("cannot get paused drivers: ", err)
+	}
+	if len(p.Drivers) != 1 || p.Drivers[0] != drvType {
+		t.Fatalf("expected %s to be quarantined, got %+v", drvType, p)
+	}
+
+	if err = s.send("panic3", "ok"); err != nil {
+		t.Fatal("cannot create panic3: ", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if st, _ := s.cl.Status("panic3"); st.State != types.PENDING {
+		t.Fatalf("expected panic3 to be PENDING in quarantine, got %+v", st)
+	}
+
+	if err = s.cl.Resume(drvType); err != nil {
+		t.Fatal("cannot resume: ", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if st, _ := s.cl.Status("panic3"); st.State != types.SUCCESS {
+		t.Errorf("expected panic3 to be sent after resuming, got %+v", st)
+	}
+}
@@ -0,0 +1,78 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testPause ensures notifications of paused driver are kept until resumed
+func (s *suite) testPause(t *testing.T) {
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		return []byte(ep), nil
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	check := func(all bool, drvs ...string) {
+		st, err := s.cl.Paused()
+		if err != nil {
+			t.Fatal("cannot get paused drivers: ", err)
+		}
+		if st.All != all || len(st.Drivers) != len(drvs) {
+			t.Fatalf("unexpected paused drivers: %+v", st)
+		}
+		for idx, d := range drvs {
+			if st.Drivers[idx] != d {
+				t.Fatalf("unexpected paused drivers: %+v", st)
+			}
+		}
+	}
+	state := func(expect types.State) {
+		st, err := s.cl.Status("pause")
+		if err != nil {
+			t.Fatal("cannot get status: ", err)
+		}
+		if st.State != expect {
+			t.Fatalf("expected %s, got %+v", expect, st)
+		}
+	}
+
+	if err := s.cl.Pause("NOT_EXIST"); err == nil {
+		t.Error("expected error when pausing unknown driver")
+	}
+
+	if err := s.cl.Pause(drvType); err != nil {
+		t.Fatal("cannot pause: ", err)
+	}
+	check(false, drvType)
+	if err := s.send("pause", "ok"); err != nil {
+		t.Fatal("cannot create notify: ", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	state(types.PENDING)
+
+	if err := s.cl.Pause(""); err != nil {
+		t.Fatal("cannot pause all: ", err)
+	}
+	check(true, drvType)
+	if err := s.cl.Resume(drvType); err != nil {
+		t.Fatal("cannot resume: ", err)
+	}
+	check(true)
+	time.Sleep(500 * time.Millisecond)
+	state(types.PENDING)
+
+	if err := s.cl.Resume(""); err != nil {
+		t.Fatal("cannot resume all: ", err)
+	}
+	check(false)
+	time.Sleep(500 * time.Millisecond)
+	state(types.SUCCESS)
+}
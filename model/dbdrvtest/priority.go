@@ -0,0 +1,57 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// testPriority ensures higher priority is served first, and long waiting ones
+// gain priority as time goes by
+func (s *suite) testPriority(t *testing.T) {
+	const drv = "PRIO" // prevent leftovers of other tests from interfering
+	now := time.Now().Unix()
+	items := []*model.Item{
+		{ID: "prio-low", NextAt: now},
+		{ID: "prio-high", NextAt: now, Priority: 5},
+		{ID: "prio-old", NextAt: now - 10*model.PriorityAging},
+	}
+	for _, i := range items {
+		i.Driver = drv
+		i.Endpoint = i.ID
+		i.Content = []byte("{}")
+		i.CreateAt = now
+		i.State = types.PENDING
+		if err := s.dbdrv.Create(i); err != nil {
+			t.Fatalf("cannot create %s: %s", i.ID, err)
+		}
+		defer s.dbdrv.Delete(i.ID)
+	}
+
+	ret, err := s.dbdrv.PendingBatch(now, 3, []string{drv}, "prio", now+60, 3)
+	if err != nil {
+		t.Fatal("cannot claim notifications: ", err)
+	}
+	for _, i := range ret {
+		defer s.dbdrv.Update(i.ID, i.Lease, 1, now, types.SUCCESS, nil)
+	}
+
+	expect := []string{"prio-old", "prio-high", "prio-low"}
+	if len(ret) != len(expect) {
+		t.Fatalf("expected %d notifications, got %d", len(expect), len(ret))
+	}
+	for idx, id := range expect {
+		if ret[idx].ID != id {
+			t.Errorf("expected #%d to be %s, got %s", idx, id, ret[idx].ID)
+		}
+	}
+	if ret[1].Priority != 5 {
+		t.Errorf("expected priority of prio-high to be 5, got %d", ret[1].Priority)
+	}
+}
@@ -0,0 +1,71 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// namedDrv is drv with different type
+type namedDrv struct {
+	drv
+	typ string
+}
+
+func (d namedDrv) Type() string { return d.typ }
+
+// testRegister ensures drivers can be registered and unregistered at runtime
+func (s *suite) testRegister(t *testing.T) {
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		return []byte(ep), nil
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	state := func(id string, expect types.State) {
+		st, err := s.cl.Status(id)
+		if err != nil {
+			t.Fatalf("cannot get status of %s: %v", id, err)
+		}
+		if st.State != expect {
+			t.Fatalf("expected %s to be %s, got %+v", id, expect, st)
+		}
+	}
+
+	api.Register(namedDrv{drv: drv(f), typ: "TEST2"})
+	if err := s.cl.Send("reg1", "TEST2", "ok", map[string][]string{}); err != nil {
+		t.Fatal("cannot create notify with new driver: ", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	state("reg1", types.SUCCESS)
+
+	api.Unregister(drvType)
+	if err := s.send("reg2", "ok"); err == nil {
+		t.Fatal("expected error when sending with unregistered driver")
+	}
+	now := time.Now().Unix()
+	err := s.dbdrv.Create(&model.Item{
+		ID:       "reg2",
+		Driver:   drvType,
+		Endpoint: "ok",
+		Content:  []byte(`{}`),
+		CreateAt: now,
+		NextAt:   now,
+	})
+	if err != nil {
+		t.Fatal("cannot create notify in db: ", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	state("reg2", types.PENDING)
+
+	api.Register(drv(f))
+	time.Sleep(time.Second)
+	state("reg2", types.SUCCESS)
+}
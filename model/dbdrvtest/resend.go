@@ -0,0 +1,38 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/model"
+)
+
+// testResendTwice ensures resending an unclaimed notification again succeeds,
+// even if nothing is changed in db
+func (s *suite) testResendTwice(t *testing.T) {
+	now := time.Now().Unix()
+	err := s.dbdrv.Create(&model.Item{
+		ID:       "resend2",
+		Driver:   "RESEND",
+		Endpoint: "resend2",
+		Content:  []byte(`{}`),
+		CreateAt: now,
+		NextAt:   now,
+	})
+	if err != nil {
+		t.Fatal("cannot create notification: ", err)
+	}
+
+	for x := 1; x <= 2; x++ {
+		if err = s.dbdrv.Resend("resend2"); err != nil {
+			t.Fatalf("cannot resend #%d: %v", x, err)
+		}
+	}
+	if _, ok := s.dbdrv.Resend("resend-unknown").(*model.E404); !ok {
+		t.Error("expected resending unknown id to return E404")
+	}
+}
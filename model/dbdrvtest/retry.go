@@ -0,0 +1,94 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testRetry ensures retry policy of notification is respected, and tried
+// counts are real
+func (s *suite) testRetry(t *testing.T) {
+	var calls int32 // calls of retry1
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		if ep == "retry1" {
+			atomic.AddInt32(&calls, 1)
+		}
+		return []byte(ep), errors.New("err")
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	err := s.cl.Send("retry1", drvType, "retry1", map[string][]string{}, types.WithRetry(types.RetryPolicy{
+		MaxTries: 2,
+		Backoff:  types.BackoffFixed,
+		Base:     1,
+	}))
+	if err != nil {
+		t.Fatal("cannot create retry1: ", err)
+	}
+	if err = s.sendOnce("retry2", "retry2"); err != nil {
+		t.Fatal("cannot create retry2: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	expect := map[string]uint32{"retry1": 2, "retry2": 1}
+	for id, tried := range expect {
+		for {
+			st, err := s.cl.Status(id)
+			if err != nil {
+				t.Fatalf("cannot get status of %s: %s", id, err)
+			}
+			if st.State == types.FAILED {
+				if st.Tried != tried {
+					t.Errorf("expected %s to be tried %d times, got %d", id, tried, st.Tried)
+				}
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatalf("%s is not failed in 10 seconds: %+v", id, st)
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+	}
+
+	// resend allows exactly one more try
+	if err := s.cl.Resend("retry2"); err != nil {
+		t.Fatal("cannot resend retry2: ", err)
+	}
+	time.Sleep(2 * time.Second)
+	st, err := s.cl.Status("retry2")
+	if err != nil {
+		t.Fatal("cannot get status of retry2: ", err)
+	}
+	if st.State != types.FAILED || st.Tried != 2 {
+		t.Errorf("unexpected status of retry2 after resend: %+v", st)
+	}
+
+	// resend does not change retry policy, requeue allows 2 tries again
+	if err = s.cl.Resend("retry1"); err != nil {
+		t.Fatal("cannot resend retry1: ", err)
+	}
+	time.Sleep(time.Second)
+	if _, err = s.cl.Requeue(types.Filter{Endpoint: "retry1"}); err != nil {
+		t.Fatal("cannot requeue retry1: ", err)
+	}
+	time.Sleep(3 * time.Second)
+	if x := atomic.LoadInt32(&calls); x != 5 {
+		t.Errorf("expected retry1 to be sent 5 times, got %d", x)
+	}
+	if st, _ = s.cl.Status("retry1"); st.State != types.FAILED {
+		t.Errorf("unexpected status of retry1 after requeue: %+v", st)
+	}
+}
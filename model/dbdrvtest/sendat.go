@@ -0,0 +1,44 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package dbdrvtest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// testSendAt ensures scheduled notification is not sent before the time
+func (s *suite) testSendAt(t *testing.T) {
+	ch := make(chan string)
+	f := func(ep string, content []byte) (resp []byte, err error) {
+		go func() { ch <- ep }()
+		return []byte(ep), nil
+	}
+	api := s.start(f)
+	defer api.Shutdown(context.Background())
+
+	at := time.Now().Add(3 * time.Second).Truncate(time.Second)
+	if err := s.cl.Send("sendat", drvType, "sendat", map[string][]string{}, types.WithSendAt(at)); err != nil {
+		t.Fatal("cannot create notify: ", err)
+	}
+
+	st, err := s.cl.Status("sendat")
+	if err != nil {
+		t.Fatal("cannot get status: ", err)
+	}
+	if st.SendAt != at.Unix() || st.NextAt != at.Unix() {
+		t.Errorf("expected to be sent at %d, got %+v", at.Unix(), st)
+	}
+
+	if _, ok := s.waitResult(time.Second, ch); ok {
+		t.Fatal("notification is sent before scheduled time")
+	}
+	if _, ok := s.waitResult(10*time.Second, ch); !ok {
+		t.Fatal("notification is not sent after scheduled time")
+	}
+}
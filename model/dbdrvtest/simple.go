@@ -73,8 +73,17 @@ func (s *suite) testSimpleDupe(t *testing.T) {
 	api := s.start(f)
 	defer api.Shutdown(context.Background())
 
-	if err := s.send("simple", "ok"); err == nil {
-		t.Fatal("sending duplicated id should return error, but got none")
+	if err := s.send("simple", "ok"); err != nil {
+		t.Fatal("replaying identical notification should succeed, but got ", err)
+	}
+
+	err := s.send("simple", "another")
+	e, ok := err.(*types.ConflictError)
+	if !ok {
+		t.Fatalf("sending duplicated id should return ConflictError, but got %v", err)
+	}
+	if e.ID != "simple" || e.Status.State != types.SUCCESS {
+		t.Fatalf("unexpected conflict: %+v", e)
 	}
 }
 
@@ -18,7 +18,6 @@ import (
 )
 
 const (
-	DrvCnt    = 1 // number of notify drivers enabled in test server
 	MaxThread = 2 // number of senders in test server
 	drvType   = "TEST"
 )
@@ -64,9 +63,12 @@ func (s *suite) waitStart() {
 		case <-ctx.Done():
 			log.Fatalf("cannot start api server")
 		default:
-			_, err := hc.Get("http://" + s.bind)
+			resp, err := hc.Get("http://" + s.bind + "/healthz")
 			if err == nil {
-				return
+				resp.Body.Close()
+				if resp.StatusCode == 200 {
+					return
+				}
 			}
 
 			time.Sleep(50 * time.Millisecond)
@@ -74,8 +76,8 @@ func (s *suite) waitStart() {
 	}
 }
 
-func (s *suite) start(f func(ep string, content []byte) (resp []byte, err error)) (ret notify.APIServer) {
-	ret, _ = notify.NewAPI(notify.SenderOptions{
+func (s *suite) create(f func(ep string, content []byte) (resp []byte, err error), mods ...func(*notify.SenderOptions)) (ret notify.APIServer) {
+	opt := notify.SenderOptions{
 		MaxTries: 3,
 		Scheduler: func(driver, notifyID string, lastExec time.Time, tried uint32) (next time.Time, stop bool) {
 			next = lastExec.Add(time.Second)
@@ -83,9 +85,18 @@ func (s *suite) start(f func(ep string, content []byte) (resp []byte, err error)
 		},
 		MaxThreads: MaxThread,
 		DBDrv:      s.dbdrv,
-	})
+	}
+	for _, m := range mods {
+		m(&opt)
+	}
+	ret, _ = notify.NewAPI(opt)
 
 	ret.Register(drv(f))
+	return
+}
+
+func (s *suite) start(f func(ep string, content []byte) (resp []byte, err error), mods ...func(*notify.SenderOptions)) (ret notify.APIServer) {
+	ret = s.create(f, mods...)
 	ret.GetHTTPServer().Addr = s.bind
 
 	go ret.Start()
@@ -111,6 +122,7 @@ func (s *suite) Run(t *testing.T) {
 	}
 	f(t.Run("SimpleOK", s.testSimpleOK))
 	f(t.Run("SimpleResend", s.testSimpleResend))
+	f(t.Run("ResendTwice", s.testResendTwice))
 	f(t.Run("SimpleDupe", s.testSimpleDupe))
 	f(t.Run("SimpleStatus", s.testSimpleStatus))
 	f(t.Run("SimpleResult", s.testSimpleResult))
@@ -118,6 +130,23 @@ func (s *suite) Run(t *testing.T) {
 	f(t.Run("SimpleDelete", s.testSimpleDelete))
 	f(t.Run("Clear", s.testClear))
 	f(t.Run("Delete", s.testDelete))
+	f(t.Run("Lease", s.testLease))
+	f(t.Run("StaleLease", s.testStaleLease))
+	f(t.Run("Priority", s.testPriority))
+	f(t.Run("SendAt", s.testSendAt))
+	f(t.Run("Expire", s.testExpire))
+	f(t.Run("Retry", s.testRetry))
+	f(t.Run("Attempts", s.testAttempts))
+	f(t.Run("Hooks", s.testHooks))
+	f(t.Run("Callback", s.testCallback))
+	f(t.Run("Metrics", s.testMetrics))
+	f(t.Run("Health", s.testHealth))
+	f(t.Run("Failed", s.testFailed))
+	f(t.Run("Pause", s.testPause))
+	f(t.Run("Register", s.testRegister))
+	f(t.Run("Journal", s.testJournal))
+	f(t.Run("Panic", s.testPanic))
+	f(t.Run("Group", s.testGroup))
 }
 
 func (s *suite) waitResult(t time.Duration, ch chan string) (ret string, ok bool) {
@@ -5,6 +5,7 @@
 package model
 
 import (
+	"context"
 	"time"
 
 	"github.com/raohwork/notify/types"
@@ -14,30 +15,104 @@ type E404 struct{}
 
 func (e *E404) Error() string { return "record not found" }
 
+// E409 denotes the operation conflicts with current state of notification,
+// like duplicated id or deleting a leased one.
+type E409 struct{}
+
+func (e *E409) Error() string { return "conflicts with existing notification" }
+
+// ELostLease denotes the lease has expired and the notification might be
+// claimed by others, so the result is discarded.
+type ELostLease struct{}
+
+func (e *ELostLease) Error() string { return "lease of notification is lost" }
+
+// ENotSupported denotes the db connection does not support the feature, see
+// Notifier
+type ENotSupported struct{}
+
+func (e *ENotSupported) Error() string { return "not supported by db connection" }
+
 // DBDrv defines db related methods
 //
 // It is possible to do some magic in this interface to affect sender, but you
 // *SHOULD NOT* do this unless you have good reason.
 type DBDrv interface {
-	// creates a notification to send
+	// creates a notification to send, return &E409{} if id exists
 	Create(i *Item) (err error)
-	// send a notification again, does not retry, return &E404{} if id not found
-	Resend(id string, max uint32) (err error)
-	// update a notification after sending. *NEVER* return error if id not found
-	Update(id string, tried uint32, next int64, state types.State, resp []byte) (err error)
+	// send a notification again by allowing exactly one more try, does not
+	// retry. Retry.MaxTries is kept, see Item.Resend. Return &E404{} if id
+	// not found, or &E409{} if it is leased
+	Resend(id string) (err error)
+	// update a notification after sending, and release the lease. lease is
+	// Item.Lease returned by Pending, return &ELostLease{} if the
+	// notification is not leased with it anymore (or id not found).
+	Update(id, lease string, tried uint32, next int64, state types.State, resp []byte) (err error)
 	// retrieve last sending result, return &E404{} if id not found
 	Result(id string) (ret []byte, err error)
 	// retrieve status, return &E404{} if id not found
 	Status(id string) (ret types.Status, err error)
 	// retrieve detail info, return &E404{} if id not found
 	Detail(id string) (ret types.Detail, err error)
-	// get one pending notification
-	Pending(now int64, max uint32, drvs, ids []string) (ret *Item, err error)
-	// delete a notification, excepts current sending notifications
+	// retrieve every stored field of a notification except Lease, return
+	// &E404{} if id not found
+	Item(id string) (ret *Item, err error)
+	// record a try of the notification
+	AddAttempt(id string, a types.Attempt) (err error)
+	// retrieve all tries of the notification in order, returns empty slice
+	// if id not found
+	Attempts(id string) (ret []types.Attempt, err error)
+	// reschedule a notification without counting as a try, and release the
+	// lease. Returns &ELostLease{} like Update.
+	Postpone(id, lease string, next int64) (err error)
+	// mark a notification as EXPIRED without sending it, and release the
+	// lease. Last response is kept. Returns &ELostLease{} like Update.
+	Expire(id, lease string, now int64) (err error)
+	// claim one pending notification by leasing it to owner until the time
+	// specified. Each claim gets an unique token in Item.Lease, which is
+	// required to update the notification. Leased notifications are invisible to other callers until
+	// the lease expires or Update/Postpone is called. It *MUST* be atomic so
+	// that multiple instances can share same db safely. drvs might be a
+	// subset of registered drivers. The one with highest Rank(now) is
+	// claimed first. max is used if Retry.MaxTries of the notification is
+	// not set.
+	Pending(now int64, max uint32, drvs []string, owner string, until int64) (ret *Item, err error)
+	// same as Pending, but claims at most n notifications at once, ordered
+	// by Rank(now) descending, then next_at
+	PendingBatch(now int64, max uint32, drvs []string, owner string, until int64, n int) (ret []*Item, err error)
+	// delete a notification and its attempts, returns &E409{} if it is
+	// leased by someone.
 	// *NEVER* return error if nothing's deleted (id not found or something)
-	Delete(id string, cur []string) (err error)
-	// clear finished notifications older than t, excepts current sending ones
-	Clear(t time.Time, cur []string) (err error)
-	// clear all notifications older than t, excepts current sending ones
-	ForceClear(t time.Time, cur []string) (err error)
+	Delete(id string) (err error)
+	// list FAILED notifications matching f ordered by create_at, returns
+	// empty slice if nothing matches
+	Failed(f types.Filter) (ret []types.Failed, err error)
+	// move all FAILED notifications matching f back to PENDING with a fresh
+	// retry budget, and schedule them at now. Like Resend, tried is kept and
+	// the budget counts from it. It returns number of notifications moved.
+	// f.Limit and f.Offset are ignored.
+	Requeue(f types.Filter, now int64) (ret int64, err error)
+	// list notifications created by same broadcast ordered by id, returns
+	// empty slice if group not found
+	Group(id string) (ret []types.GroupMember, err error)
+	// count notifications grouped by driver and state
+	Count() (ret []Count, err error)
+	// checks db connection
+	Ping(ctx context.Context) (err error)
+	// clear finished notifications older than t and their attempts,
+	// excepts leased ones
+	Clear(t time.Time) (err error)
+	// clear all notifications older than t and their attempts, excepts
+	// leased ones
+	ForceClear(t time.Time) (err error)
+}
+
+// Notifier is an optional interface a DBDrv can implement, so senders of all
+// instances sharing the db are waken up as soon as a notification is created,
+// instead of finding it by polling.
+type Notifier interface {
+	// blocks until ctx is done or connection is broken, calls wake every
+	// time a notification is created by any instance. Returns
+	// &ENotSupported{} if it never works with current db connection.
+	Listen(ctx context.Context, wake func()) (err error)
 }
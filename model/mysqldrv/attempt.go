@@ -0,0 +1,66 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package mysqldrv
+
+import "github.com/raohwork/notify/types"
+
+const qAttemptTable = "CREATE TABLE IF NOT EXISTS attempts (`attempt_id` bigint UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY, `notify_id` varchar(128) NOT NULL, `tried` int UNSIGNED NOT NULL, `exec_at` bigint NOT NULL, `duration` bigint NOT NULL, `error` text NOT NULL, `response` blob NULL, `thread` varchar(160) NOT NULL, INDEX `notify_key` (`notify_id`))"
+
+const qAddAttempt = `INSERT INTO attempts
+  (notify_id,tried,exec_at,duration,error,response,thread)
+VALUES
+  (?,?,?,?,?,?,?)`
+
+func (d *mysqldrv) AddAttempt(id string, a types.Attempt) (err error) {
+	stmt := d.Stmt(qAddAttempt)
+	_, err = stmt.Exec(
+		id, a.Tried,
+		a.At, a.Duration,
+		a.Error, a.Response,
+		a.Thread,
+	)
+	return
+}
+
+const qAttempts = `SELECT
+  tried, exec_at,
+  duration, error,
+  response, thread
+FROM attempts
+WHERE notify_id=?
+ORDER BY attempt_id ASC`
+
+func (d *mysqldrv) Attempts(id string) (ret []types.Attempt, err error) {
+	rows, err := d.Stmt(qAttempts).Query(id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []types.Attempt{}
+	for rows.Next() {
+		var a types.Attempt
+		err = rows.Scan(
+			&a.Tried,
+			&a.At,
+			&a.Duration,
+			&a.Error,
+			&a.Response,
+			&a.Thread,
+		)
+		if err != nil {
+			return
+		}
+		ret = append(ret, a)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// attempts of deleted notifications
+const qPruneAttempts = `DELETE FROM attempts WHERE notify_id NOT IN (SELECT notify_id FROM items)`
+
+const qDeleteAttempts = `DELETE FROM attempts WHERE notify_id=?`
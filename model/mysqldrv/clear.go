@@ -8,33 +8,24 @@ import (
 	"time"
 )
 
-const qClear = "DELETE FROM items WHERE create_at < ? AND cur_state IN (1,2) AND notify_id NOT IN (%s)"
+const qClear = "DELETE FROM items WHERE create_at < ? AND cur_state IN (1,2,3) AND lease_until<=?"
 
-var qClearReal string
-
-func (d *mysqldrv) Clear(t time.Time, cur []string) (err error) {
-	stmt := d.Stmt(qClearReal)
-	args := make([]interface{}, 1, len(cur)+1)
-	args[0] = t.Unix()
-	for _, id := range cur {
-		args = append(args, id)
+func (d *mysqldrv) Clear(t time.Time) (err error) {
+	stmt := d.Stmt(qClear)
+	if _, err = stmt.Exec(t.Unix(), time.Now().Unix()); err != nil {
+		return
 	}
-
-	_, err = stmt.Exec(args...)
+	_, err = d.Stmt(qPruneAttempts).Exec()
 	return
 }
 
-const qForceClear = "DELETE FROM items WHERE create_at < ? AND notify_id NOT IN (%s)"
-
-var qForceClearReal string
+const qForceClear = "DELETE FROM items WHERE create_at < ? AND lease_until<=?"
 
-func (d *mysqldrv) ForceClear(t time.Time, cur []string) (err error) {
-	stmt := d.Stmt(qForceClearReal)
-	args := make([]interface{}, 1, len(cur)+1)
-	args[0] = t.Unix()
-	for _, id := range cur {
-		args = append(args, id)
+func (d *mysqldrv) ForceClear(t time.Time) (err error) {
+	stmt := d.Stmt(qForceClear)
+	if _, err = stmt.Exec(t.Unix(), time.Now().Unix()); err != nil {
+		return
 	}
-	_, err = stmt.Exec(args...)
+	_, err = d.Stmt(qPruneAttempts).Exec()
 	return
 }
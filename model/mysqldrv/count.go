@@ -0,0 +1,31 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package mysqldrv
+
+import "github.com/raohwork/notify/model"
+
+const qCount = `SELECT driver, cur_state, COUNT(*)
+FROM items
+GROUP BY driver, cur_state`
+
+func (d *mysqldrv) Count() (ret []model.Count, err error) {
+	rows, err := d.Stmt(qCount).Query()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []model.Count{}
+	for rows.Next() {
+		var c model.Count
+		if err = rows.Scan(&c.Driver, &c.State, &c.Count); err != nil {
+			return
+		}
+		ret = append(ret, c)
+	}
+
+	err = rows.Err()
+	return
+}
@@ -4,12 +4,22 @@
 
 package mysqldrv
 
-import "github.com/raohwork/notify/model"
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/raohwork/notify/model"
+)
+
+// error number of duplicate key
+const errDupe = 1062
 
 const qCreate = `INSERT INTO items
-  (notify_id,driver,endpoint,content,create_at,next_at,tried)
+  (notify_id,driver,endpoint,content,create_at,next_at,tried,priority,send_at,expire_at,
+   max_tries,backoff,backoff_base,backoff_cap,
+   callback,group_id)
 VALUES
-  (?,?,?,?,?,?,?)`
+  (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
 
 func (d *mysqldrv) Create(i *model.Item) (err error) {
 	stmt := d.Stmt(qCreate)
@@ -17,6 +27,14 @@ func (d *mysqldrv) Create(i *model.Item) (err error) {
 		i.ID, i.Driver,
 		i.Endpoint, i.Content,
 		i.CreateAt, i.NextAt, i.Tried,
+		i.Priority, i.SendAt, i.ExpireAt,
+		i.Retry.MaxTries, i.Retry.Backoff,
+		i.Retry.Base, i.Retry.Cap,
+		i.Callback, i.Group,
 	)
+	var e *mysql.MySQLError
+	if errors.As(err, &e) && e.Number == errDupe {
+		err = &model.E409{}
+	}
 	return
 }
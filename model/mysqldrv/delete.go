@@ -4,17 +4,38 @@
 
 package mysqldrv
 
-import "errors"
+import (
+	"time"
 
-const qDelete = "DELETE FROM items WHERE notify_id=?"
+	"github.com/raohwork/notify/model"
+)
 
-func (d *mysqldrv) Delete(id string, ids []string) (err error) {
-	for _, i := range ids {
-		if id == i {
-			return errors.New("notification is processing, cannot delete")
-		}
-	}
+const qDelete = "DELETE FROM items WHERE notify_id=? AND lease_until<=?"
+
+const qLeased = "SELECT COUNT(*) FROM items WHERE notify_id=? AND lease_until>?"
+
+func (d *mysqldrv) Delete(id string) (err error) {
+	now := time.Now().Unix()
 	stmt := d.Stmt(qDelete)
-	_, err = stmt.Exec(id)
+	res, err := stmt.Exec(id, now)
+	if err != nil {
+		return
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if cnt > 0 {
+		_, err = d.Stmt(qDeleteAttempts).Exec(id)
+		return
+	}
+
+	var leased int
+	if err = d.Stmt(qLeased).QueryRow(id, now).Scan(&leased); err != nil {
+		return
+	}
+	if leased > 0 {
+		err = &model.E409{}
+	}
 	return
 }
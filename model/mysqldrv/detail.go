@@ -15,7 +15,8 @@ const qDetail = `SELECT
   response, driver,
   endpoint, content,
   create_at, next_at,
-  tried, cur_state
+  tried, cur_state,
+  send_at, expire_at
 FROM items
 WHERE notify_id=? LIMIT 1`
 
@@ -28,6 +29,8 @@ func (d *mysqldrv) Detail(id string) (ret types.Detail, err error) {
 		next   int64
 		try    uint32
 		state  int
+		at     int64
+		expire int64
 		resp   []byte
 	)
 
@@ -42,6 +45,8 @@ func (d *mysqldrv) Detail(id string) (ret types.Detail, err error) {
 		&next,
 		&try,
 		&state,
+		&at,
+		&expire,
 	)
 	if err == sql.ErrNoRows {
 		err = &model.E404{}
@@ -60,6 +65,8 @@ func (d *mysqldrv) Detail(id string) (ret types.Detail, err error) {
 			NextAt:   next,
 			Tried:    try,
 			State:    types.State(state),
+			SendAt:   at,
+			ExpireAt: expire,
 		},
 	}
 	return
@@ -9,21 +9,26 @@ package mysqldrv
 
 import (
 	"database/sql"
-	"fmt"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/raohwork/notify/model"
 )
 
 type mysqldrv struct {
+	seq    uint64   // used to generate lease token, see Pending()
+	claims sync.Map // number of drivers => prepared qClaim, see claimStmt()
 	*model.DrvBase
 }
 
 // New creates a db driver with mysql
 //
-// It will create neccessary table is not exists.
-func New(conn *sql.DB, drvCnt int, maxThread int) (ret model.DBDrv, err error) {
+// It will create neccessary table is not exists, and add missing columns to
+// table created by previous version.
+func New(conn *sql.DB) (ret model.DBDrv, err error) {
 	d := &mysqldrv{
+		// tokens must not be reused after restarting, see Update()
+		seq:     uint64(time.Now().UnixNano()),
 		DrvBase: model.NewDrvBase(conn),
 	}
 
@@ -31,22 +36,37 @@ func New(conn *sql.DB, drvCnt int, maxThread int) (ret model.DBDrv, err error) {
 	if err = d.table(); err != nil {
 		return
 	}
+	if err = d.migrate(); err != nil {
+		return
+	}
 
 	err = d.Prepare(qCreate, err)
 	err = d.Prepare(qResend, err)
 	err = d.Prepare(qUpdate, err)
+	err = d.Prepare(qPostpone, err)
+	err = d.Prepare(qExpire, err)
 	err = d.Prepare(qResult, err)
 	err = d.Prepare(qDelete, err)
 	err = d.Prepare(qStatus, err)
 	err = d.Prepare(qDetail, err)
-	drv := strings.Repeat(",?", drvCnt)[1:]
-	ids := strings.Repeat(",?", maxThread)[1:]
-	qPendingReal = fmt.Sprintf(qPending, drv, ids)
-	err = d.Prepare(qPendingReal, err)
-	qClearReal = fmt.Sprintf(qClear, ids)
-	err = d.Prepare(qClearReal, err)
-	qForceClearReal = fmt.Sprintf(qForceClear, ids)
-	err = d.Prepare(qForceClearReal, err)
+	err = d.Prepare(qItem, err)
+	err = d.Prepare(qLeased, err)
+	err = d.Prepare(qLeaseUntil, err)
+	err = d.Prepare(qClear, err)
+	err = d.Prepare(qForceClear, err)
+	err = d.Prepare(qClaimed, err)
+	err = d.Prepare(qAddAttempt, err)
+	err = d.Prepare(qAttempts, err)
+	err = d.Prepare(qPruneAttempts, err)
+	err = d.Prepare(qDeleteAttempts, err)
+	err = d.Prepare(qCount, err)
+	err = d.Prepare(qGroup, err)
+	err = d.Prepare(qFailed, err)
+	err = d.Prepare(qRequeue, err)
+	if err == nil {
+		// check syntax
+		_, err = d.claimStmt(1)
+	}
 
 	if err == nil {
 		ret = d
@@ -54,9 +74,77 @@ func New(conn *sql.DB, drvCnt int, maxThread int) (ret model.DBDrv, err error) {
 	return
 }
 
-const qTable = "CREATE TABLE IF NOT EXISTS items (`notify_id` varchar(128) NOT NULL PRIMARY KEY, `driver` varchar(16) NOT NULL, `endpoint` text NOT NULL, `content` blob NOT NULL, `create_at` bigint NOT NULL, `next_at` bigint NOT NULL, `tried` int UNSIGNED NOT NULL DEFAULT 0, `cur_state` tinyint(1) NOT NULL DEFAULT 0, `response` blob NULL, INDEX `pending_key` (`next_at`), INDEX `creation_key` (`create_at`))"
+const qTable = "CREATE TABLE IF NOT EXISTS items (`notify_id` varchar(128) NOT NULL PRIMARY KEY, `driver` varchar(16) NOT NULL, `endpoint` text NOT NULL, `content` blob NOT NULL, `create_at` bigint NOT NULL, `next_at` bigint NOT NULL, `tried` int UNSIGNED NOT NULL DEFAULT 0, `cur_state` tinyint(1) NOT NULL DEFAULT 0, `response` blob NULL, `lease_owner` varchar(128) NOT NULL DEFAULT '', `lease_until` bigint NOT NULL DEFAULT 0, `priority` int NOT NULL DEFAULT 0, `send_at` bigint NOT NULL DEFAULT 0, `expire_at` bigint NOT NULL DEFAULT 0, `max_tries` int UNSIGNED NOT NULL DEFAULT 0, `backoff` varchar(16) NOT NULL DEFAULT '', `backoff_base` int UNSIGNED NOT NULL DEFAULT 0, `backoff_cap` int UNSIGNED NOT NULL DEFAULT 0, `callback` blob NULL, `group_id` varchar(128) NOT NULL DEFAULT '', `base_tries` int UNSIGNED NOT NULL DEFAULT 0, `resend` tinyint(1) NOT NULL DEFAULT 0, INDEX `pending_key` (`next_at`), INDEX `creation_key` (`create_at`), INDEX `group_key` (`group_id`), INDEX `lease_key` (`lease_owner`))"
 
 func (d *mysqldrv) table() (err error) {
-	_, err = d.DB.Exec(qTable)
+	if _, err = d.DB.Exec(qTable); err != nil {
+		return
+	}
+	_, err = d.DB.Exec(qAttemptTable)
+	return
+}
+
+// columns added after first release, in the order they were added
+var columns = []struct{ name, def string }{
+	{"lease_owner", "varchar(128) NOT NULL DEFAULT ''"},
+	{"lease_until", "bigint NOT NULL DEFAULT 0"},
+	{"priority", "int NOT NULL DEFAULT 0"},
+	{"send_at", "bigint NOT NULL DEFAULT 0"},
+	{"expire_at", "bigint NOT NULL DEFAULT 0"},
+	{"max_tries", "int UNSIGNED NOT NULL DEFAULT 0"},
+	{"backoff", "varchar(16) NOT NULL DEFAULT ''"},
+	{"backoff_base", "int UNSIGNED NOT NULL DEFAULT 0"},
+	{"backoff_cap", "int UNSIGNED NOT NULL DEFAULT 0"},
+	{"callback", "blob NULL"},
+	// index is added together as it is needed only if column is missing
+	{"group_id", "varchar(128) NOT NULL DEFAULT '', ADD INDEX `group_key` (`group_id`)"},
+	{"base_tries", "int UNSIGNED NOT NULL DEFAULT 0"},
+	{"resend", "tinyint(1) NOT NULL DEFAULT 0"},
+}
+
+const qColumn = `SELECT COUNT(*) FROM information_schema.columns
+WHERE table_schema=DATABASE() AND table_name='items' AND column_name=?`
+
+// indexes added after first release, for columns existed before them
+var indexes = []struct{ name, def string }{
+	{"lease_key", "(`lease_owner`)"},
+}
+
+const qIndex = `SELECT COUNT(*) FROM information_schema.statistics
+WHERE table_schema=DATABASE() AND table_name='items' AND index_name=?`
+
+// migrate adds missing columns and indexes since mysql does not support
+// "ADD COLUMN IF NOT EXISTS"
+func (d *mysqldrv) migrate() (err error) {
+	for _, c := range columns {
+		var cnt int
+		if err = d.DB.QueryRow(qColumn, c.name).Scan(&cnt); err != nil {
+			return
+		}
+		if cnt > 0 {
+			continue
+		}
+
+		_, err = d.DB.Exec("ALTER TABLE items ADD COLUMN `" + c.name + "` " + c.def)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, i := range indexes {
+		var cnt int
+		if err = d.DB.QueryRow(qIndex, i.name).Scan(&cnt); err != nil {
+			return
+		}
+		if cnt > 0 {
+			continue
+		}
+
+		_, err = d.DB.Exec("ALTER TABLE items ADD INDEX `" + i.name + "` " + i.def)
+		if err != nil {
+			return
+		}
+	}
+
 	return
 }
@@ -0,0 +1,85 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package mysqldrv
+
+import "github.com/raohwork/notify/types"
+
+// conditions of types.Filter, see filterArgs
+const qFilter = `(?='' OR driver=?)
+  AND (?='' OR endpoint=?)
+  AND (?=0 OR create_at>=?)
+  AND (?=0 OR create_at<?)`
+
+func filterArgs(f types.Filter) (ret []interface{}) {
+	after, before := int64(f.After), int64(f.Before)
+	return []interface{}{
+		f.Driver, f.Driver,
+		f.Endpoint, f.Endpoint,
+		after, after,
+		before, before,
+	}
+}
+
+const qFailed = `SELECT
+  notify_id, driver,
+  endpoint, content,
+  response, create_at,
+  next_at, tried,
+  cur_state, send_at,
+  expire_at
+FROM items
+WHERE cur_state=2 AND ` + qFilter + `
+ORDER BY create_at ASC, notify_id ASC
+LIMIT ? OFFSET ?`
+
+func (d *mysqldrv) Failed(f types.Filter) (ret []types.Failed, err error) {
+	args := append(filterArgs(f), f.Limit, f.Offset)
+	rows, err := d.Stmt(qFailed).Query(args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []types.Failed{}
+	for rows.Next() {
+		var x types.Failed
+		err = rows.Scan(
+			&x.ID,
+			&x.Driver,
+			&x.Endpoint,
+			&x.Content,
+			&x.Response,
+			&x.CreateAt,
+			&x.NextAt,
+			&x.Tried,
+			&x.State,
+			&x.SendAt,
+			&x.ExpireAt,
+		)
+		if err != nil {
+			return
+		}
+		ret = append(ret, x)
+	}
+
+	err = rows.Err()
+	return
+}
+
+const qRequeue = `UPDATE items SET
+  next_at=?, cur_state=0,
+  base_tries=tried, resend=0,
+  lease_owner='', lease_until=0
+WHERE cur_state=2 AND ` + qFilter
+
+func (d *mysqldrv) Requeue(f types.Filter, now int64) (ret int64, err error) {
+	args := append([]interface{}{now}, filterArgs(f)...)
+	res, err := d.Stmt(qRequeue).Exec(args...)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,48 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package mysqldrv
+
+import "github.com/raohwork/notify/types"
+
+const qGroup = `SELECT
+  notify_id, driver,
+  endpoint, create_at,
+  next_at, tried,
+  cur_state, send_at,
+  expire_at
+FROM items
+WHERE group_id=?
+ORDER BY notify_id ASC`
+
+func (d *mysqldrv) Group(id string) (ret []types.GroupMember, err error) {
+	rows, err := d.Stmt(qGroup).Query(id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []types.GroupMember{}
+	for rows.Next() {
+		var x types.GroupMember
+		err = rows.Scan(
+			&x.ID,
+			&x.Driver,
+			&x.Endpoint,
+			&x.CreateAt,
+			&x.NextAt,
+			&x.Tried,
+			&x.State,
+			&x.SendAt,
+			&x.ExpireAt,
+		)
+		if err != nil {
+			return
+		}
+		ret = append(ret, x)
+	}
+
+	err = rows.Err()
+	return
+}
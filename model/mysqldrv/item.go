@@ -0,0 +1,69 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package mysqldrv
+
+import (
+	"database/sql"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// columns of model.Item, see scanItem
+const qItemCols = `notify_id, driver,
+  endpoint, content,
+  create_at, next_at,
+  tried, cur_state,
+  priority, send_at,
+  expire_at, max_tries,
+  backoff, backoff_base,
+  backoff_cap, callback,
+  group_id, base_tries,
+  resend`
+
+// scanItem reads a row selected with qItemCols
+func scanItem(row interface{ Scan(...interface{}) error }) (ret *model.Item, err error) {
+	var (
+		i     model.Item
+		state int
+	)
+	err = row.Scan(
+		&i.ID,
+		&i.Driver,
+		&i.Endpoint,
+		&i.Content,
+		&i.CreateAt,
+		&i.NextAt,
+		&i.Tried,
+		&state,
+		&i.Priority,
+		&i.SendAt,
+		&i.ExpireAt,
+		&i.Retry.MaxTries,
+		&i.Retry.Backoff,
+		&i.Retry.Base,
+		&i.Retry.Cap,
+		&i.Callback,
+		&i.Group,
+		&i.BaseTries,
+		&i.Resend,
+	)
+	if err != nil {
+		return
+	}
+
+	i.State = types.State(state)
+	return &i, nil
+}
+
+const qItem = `SELECT ` + qItemCols + ` FROM items WHERE notify_id=? LIMIT 1`
+
+func (d *mysqldrv) Item(id string) (ret *model.Item, err error) {
+	ret, err = scanItem(d.Stmt(qItem).QueryRow(id))
+	if err == sql.ErrNoRows {
+		err = &model.E404{}
+	}
+	return
+}
@@ -24,7 +24,7 @@ func TestMysqlDRV(t *testing.T) {
 		t.Fatal("cannot connect to db: ", err)
 	}
 
-	drv, err := New(db, dbdrvtest.DrvCnt, dbdrvtest.MaxThread)
+	drv, err := New(db)
 	if err != nil {
 		t.Fatal("cannot create mysql db driver: ", err)
 	}
@@ -6,80 +6,106 @@ package mysqldrv
 
 import (
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/raohwork/notify/model"
-	"github.com/raohwork/notify/types"
 )
 
-const qPending = `SELECT
-  notify_id, driver,
-  endpoint, content,
-  create_at, next_at,
-  tried, cur_state
-FROM items
+// mysql 5.7 does not support "SKIP LOCKED", so we claim rows with single
+// UPDATE statement (which is atomic), and find them back with an unique token.
+const qClaim = `UPDATE items SET lease_owner=?, lease_until=?
 WHERE cur_state=0
   AND next_at<=?
-  AND tried<?
+  AND tried < base_tries + CASE WHEN resend=1 THEN 1 WHEN max_tries>0 THEN max_tries ELSE ? END
+  AND lease_until<=?
   AND driver IN (%s)
-  AND notify_id NOT IN (%s)
-ORDER BY next_at ASC
-LIMIT 1`
-
-var qPendingReal string
-
-func (d *mysqldrv) Pending(now int64, max uint32, drvs, ids []string) (ret *model.Item, err error) {
-	var (
-		id     string
-		drv    string
-		ep     string
-		c      []byte
-		create int64
-		next   int64
-		try    uint32
-		state  int
-	)
-
-	params := make([]interface{}, 0, len(drvs)+len(ids)+1)
-	params = append(params, now, max)
-	for _, d := range drvs {
-		params = append(params, d)
+ORDER BY priority + (? - next_at) DIV %d DESC, next_at ASC
+LIMIT ?`
+
+// claimStmt prepares qClaim for cnt drivers, and caches it as drivers can be
+// registered at runtime.
+func (d *mysqldrv) claimStmt(cnt int) (ret *sql.Stmt, err error) {
+	if x, ok := d.claims.Load(cnt); ok {
+		return x.(*sql.Stmt), nil
 	}
-	for _, d := range ids {
+
+	drv := strings.Repeat(",?", cnt)[1:]
+	ret, err = d.DB.Prepare(fmt.Sprintf(qClaim, drv, model.PriorityAging))
+	if err != nil {
+		return
+	}
+	if x, loaded := d.claims.LoadOrStore(cnt, ret); loaded {
+		ret.Close()
+		ret = x.(*sql.Stmt)
+	}
+	return
+}
+
+const qClaimed = `SELECT ` + qItemCols + ` FROM items WHERE lease_owner=?`
+
+func (d *mysqldrv) token(owner string) (ret string) {
+	seq := atomic.AddUint64(&d.seq, 1)
+	return owner + "/" + strconv.FormatUint(seq, 36)
+}
+
+func (d *mysqldrv) Pending(now int64, max uint32, drvs []string, owner string, until int64) (ret *model.Item, err error) {
+	arr, err := d.PendingBatch(now, max, drvs, owner, until, 1)
+	if err == nil && len(arr) > 0 {
+		ret = arr[0]
+	}
+	return
+}
+
+func (d *mysqldrv) PendingBatch(now int64, max uint32, drvs []string, owner string, until int64, n int) (ret []*model.Item, err error) {
+	if len(drvs) == 0 {
+		return
+	}
+	stmt, err := d.claimStmt(len(drvs))
+	if err != nil {
+		return
+	}
+
+	token := d.token(owner)
+	params := make([]interface{}, 0, len(drvs)+7)
+	params = append(params, token, until, now, max, now)
+	for _, d := range drvs {
 		params = append(params, d)
 	}
+	params = append(params, now, n)
 
+	res, err := stmt.Exec(params...)
 	if err != nil {
 		return
 	}
+	cnt, err := res.RowsAffected()
+	if err != nil || cnt == 0 {
+		return
+	}
 
-	stmt := d.Stmt(qPendingReal)
-	row := stmt.QueryRow(params...)
-	err = row.Scan(
-		&id,
-		&drv,
-		&ep,
-		&c,
-		&create,
-		&next,
-		&try,
-		&state,
-	)
+	rows, err := d.Stmt(qClaimed).Query(token)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			err = nil
-		}
 		return
 	}
+	defer rows.Close()
+
+	ret = make([]*model.Item, 0, cnt)
+	for rows.Next() {
+		var i *model.Item
+		if i, err = scanItem(rows); err != nil {
+			return
+		}
+		i.Lease = token
+		ret = append(ret, i)
+	}
 
-	ret = &model.Item{
-		ID:       id,
-		Driver:   drv,
-		Endpoint: ep,
-		Content:  c,
-		CreateAt: create,
-		NextAt:   next,
-		Tried:    try,
-		State:    types.State(state),
+	if err = rows.Err(); err != nil {
+		return
 	}
+
+	// ORDER BY is lost as we find them back by token
+	model.SortPending(ret, now)
 	return
 }
@@ -4,20 +4,43 @@
 
 package mysqldrv
 
-import "github.com/raohwork/notify/model"
+import (
+	"database/sql"
+	"time"
 
-const qResend = `UPDATE items SET tried=?, cur_state=0 WHERE notify_id=?`
+	"github.com/raohwork/notify/model"
+)
 
-func (d *mysqldrv) Resend(id string, max uint32) (err error) {
+// max_tries is kept, so requeueing it later uses original retry policy. Lease
+// is cleared so journaled result of previous try cannot overwrite it.
+const qResend = `UPDATE items SET
+  base_tries=tried, resend=1, cur_state=0,
+  lease_owner='', lease_until=0
+WHERE notify_id=? AND lease_until<=?`
+
+const qLeaseUntil = "SELECT lease_until FROM items WHERE notify_id=?"
+
+func (d *mysqldrv) Resend(id string) (err error) {
+	now := time.Now().Unix()
 	stmt := d.Stmt(qResend)
-	res, err := stmt.Exec(max-1, id)
+	res, err := stmt.Exec(id, now)
 	if err != nil {
 		return
 	}
 
 	cnt, err := res.RowsAffected()
-	if err == nil && cnt != 1 {
+	if err != nil || cnt == 1 {
+		return
+	}
+
+	// mysql does not count rows not changed, like resending twice
+	var until int64
+	err = d.Stmt(qLeaseUntil).QueryRow(id).Scan(&until)
+	switch {
+	case err == sql.ErrNoRows:
 		err = &model.E404{}
+	case err == nil && until > now:
+		err = &model.E409{}
 	}
 	return
 }
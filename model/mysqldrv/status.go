@@ -13,7 +13,8 @@ import (
 
 const qStatus = `SELECT 
   create_at, next_at,
-  tried, cur_state
+  tried, cur_state,
+  send_at, expire_at
 FROM items
 WHERE notify_id=? LIMIT 1`
 
@@ -23,6 +24,8 @@ func (d *mysqldrv) Status(id string) (ret types.Status, err error) {
 		next   int64
 		try    uint32
 		state  int
+		at     int64
+		expire int64
 	)
 
 	stmt := d.Stmt(qStatus)
@@ -32,6 +35,8 @@ func (d *mysqldrv) Status(id string) (ret types.Status, err error) {
 		&next,
 		&try,
 		&state,
+		&at,
+		&expire,
 	)
 	if err == sql.ErrNoRows {
 		err = &model.E404{}
@@ -45,6 +50,8 @@ func (d *mysqldrv) Status(id string) (ret types.Status, err error) {
 		NextAt:   next,
 		Tried:    try,
 		State:    types.State(state),
+		SendAt:   at,
+		ExpireAt: expire,
 	}
 	return
 }
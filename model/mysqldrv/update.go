@@ -4,14 +4,50 @@
 
 package mysqldrv
 
-import "github.com/raohwork/notify/types"
+import (
+	"database/sql"
+
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
+)
+
+// leased converts result of updating leased notification. Affected rows is
+// always 1 if matched, as lease_owner is changed.
+func leased(res sql.Result, e error) (err error) {
+	if err = e; err != nil {
+		return
+	}
+	cnt, err := res.RowsAffected()
+	if err == nil && cnt == 0 {
+		err = &model.ELostLease{}
+	}
+	return
+}
 
 const qUpdate = `UPDATE items SET
-  tried=?, next_at=?, cur_state=?, response=?
-WHERE notify_id=?`
+  tried=?, next_at=?, cur_state=?, response=?,
+  lease_owner='', lease_until=0
+WHERE notify_id=? AND lease_owner=?`
 
-func (d *mysqldrv) Update(id string, tried uint32, next int64, state types.State, resp []byte) (err error) {
+func (d *mysqldrv) Update(id, lease string, tried uint32, next int64, state types.State, resp []byte) (err error) {
 	stmt := d.Stmt(qUpdate)
-	_, err = stmt.Exec(tried, next, state, resp, id)
-	return
+	return leased(stmt.Exec(tried, next, state, resp, id, lease))
+}
+
+const qPostpone = `UPDATE items SET
+  next_at=?, lease_owner='', lease_until=0
+WHERE notify_id=? AND lease_owner=?`
+
+func (d *mysqldrv) Postpone(id, lease string, next int64) (err error) {
+	stmt := d.Stmt(qPostpone)
+	return leased(stmt.Exec(next, id, lease))
+}
+
+const qExpire = `UPDATE items SET
+  next_at=?, cur_state=?, lease_owner='', lease_until=0
+WHERE notify_id=? AND lease_owner=?`
+
+func (d *mysqldrv) Expire(id, lease string, now int64) (err error) {
+	stmt := d.Stmt(qExpire)
+	return leased(stmt.Exec(now, types.EXPIRED, id, lease))
 }
@@ -10,8 +10,7 @@ package pgsqldrv
 import (
 	"database/sql"
 	"fmt"
-	"strconv"
-	"strings"
+	"time"
 
 	"github.com/raohwork/notify/model"
 )
@@ -24,15 +23,23 @@ import (
 //      initialized before actually using it.
 //   3. Prepare sql statements at first to prevent sql syntax error.
 type drv struct {
+	seq uint64 // used to generate lease token, see PendingBatch()
 	*model.DrvBase
 	stmts []string
 }
 
 // New creates a db driver with postgresql
 //
-// It creates neccesary table and index if not exists
-func New(conn *sql.DB, drvCnt, maxThread int) (ret model.DBDrv, err error) {
+// It creates neccesary table and index if not exists, and adds missing columns
+// to table created by previous version. It uses "ADD COLUMN IF NOT EXISTS" and
+// "SKIP LOCKED", so postgresql 9.6+ is required.
+//
+// The driver implements model.Notifier with LISTEN/NOTIFY if you are using
+// github.com/jackc/pgx/v4/stdlib.
+func New(conn *sql.DB) (ret model.DBDrv, err error) {
 	d := &drv{
+		// tokens must not be reused after restarting, see Update()
+		seq:     uint64(time.Now().UnixNano()),
 		DrvBase: model.NewDrvBase(conn),
 		stmts:   make([]string, qend),
 	}
@@ -47,6 +54,19 @@ next_at bigint NOT NULL,
 tried integer NOT NULL DEFAULT 0,
 cur_state smallint NOT NULL DEFAULT 0,
 response bytea NULL,
+lease_owner varchar(128) NOT NULL DEFAULT '',
+lease_until bigint NOT NULL DEFAULT 0,
+priority integer NOT NULL DEFAULT 0,
+send_at bigint NOT NULL DEFAULT 0,
+expire_at bigint NOT NULL DEFAULT 0,
+max_tries integer NOT NULL DEFAULT 0,
+backoff varchar(16) NOT NULL DEFAULT '',
+backoff_base integer NOT NULL DEFAULT 0,
+backoff_cap integer NOT NULL DEFAULT 0,
+callback bytea NULL,
+group_id varchar(128) NOT NULL DEFAULT '',
+base_tries bigint NOT NULL DEFAULT 0,
+resend boolean NOT NULL DEFAULT false,
 CONSTRAINT items_pk PRIMARY KEY (notify_id)
 )`
 	const idx1 = `CREATE INDEX IF NOT EXISTS items_pending_idx 
@@ -55,6 +75,12 @@ ON items USING btree
 	const idx2 = `CREATE INDEX IF NOT EXISTS clear_idx 
 ON items USING btree
 (create_at ASC NULLS LAST)`
+	const idx3 = `CREATE INDEX IF NOT EXISTS items_group_idx
+ON items USING btree
+(group_id ASC NULLS LAST)`
+	const idx4 = `CREATE INDEX IF NOT EXISTS items_lease_idx
+ON items USING btree
+(lease_owner ASC NULLS LAST)`
 
 	if _, err = conn.Exec(qstr); err != nil {
 		return
@@ -65,8 +91,26 @@ ON items USING btree
 	if _, err = conn.Exec(idx2); err != nil {
 		return
 	}
+	if _, err = conn.Exec(qAttemptTable); err != nil {
+		return
+	}
+	if _, err = conn.Exec(qAttemptIdx); err != nil {
+		return
+	}
+	for _, qstr := range migrations {
+		if _, err = conn.Exec(qstr); err != nil {
+			return
+		}
+	}
+	// group_id and lease_owner might be added by migrations
+	if _, err = conn.Exec(idx3); err != nil {
+		return
+	}
+	if _, err = conn.Exec(idx4); err != nil {
+		return
+	}
 
-	d.createSql(drvCnt, maxThread)
+	d.createSql()
 	if err = d.prepareSql(); err != nil {
 		return
 	}
@@ -77,6 +121,38 @@ ON items USING btree
 	return
 }
 
+const qAttemptTable = `CREATE TABLE IF NOT EXISTS attempts (
+attempt_id bigserial NOT NULL,
+notify_id varchar(128) NOT NULL,
+tried integer NOT NULL,
+exec_at bigint NOT NULL,
+duration bigint NOT NULL,
+error text NOT NULL,
+response bytea NULL,
+thread varchar(160) NOT NULL,
+CONSTRAINT attempts_pk PRIMARY KEY (attempt_id)
+)`
+const qAttemptIdx = `CREATE INDEX IF NOT EXISTS attempts_notify_idx
+ON attempts USING btree
+(notify_id ASC NULLS LAST)`
+
+// columns added after first release, in the order they were added
+var migrations = []string{
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS lease_owner varchar(128) NOT NULL DEFAULT ''`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS lease_until bigint NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS priority integer NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS send_at bigint NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS expire_at bigint NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS max_tries integer NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS backoff varchar(16) NOT NULL DEFAULT ''`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS backoff_base integer NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS backoff_cap integer NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS callback bytea NULL`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS group_id varchar(128) NOT NULL DEFAULT ''`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS base_tries bigint NOT NULL DEFAULT 0`,
+	`ALTER TABLE items ADD COLUMN IF NOT EXISTS resend boolean NOT NULL DEFAULT false`,
+}
+
 func (d *drv) prepareSql() (err error) {
 	for _, qstr := range d.stmts {
 		err = d.Prepare(qstr, err)
@@ -89,15 +165,6 @@ func (d *drv) stmt(key int) (ret *sql.Stmt) {
 	return d.Stmt(d.stmts[key])
 }
 
-func genvar(begin, cnt int) (ret string) {
-	arr := make([]string, 0, cnt)
-	for i := 0; i < cnt; i++ {
-		arr = append(arr, "$"+strconv.Itoa(begin+i))
-	}
-
-	return strings.Join(arr, ",")
-}
-
 const (
 	qCreate = iota
 	qDelete
@@ -109,40 +176,113 @@ const (
 	qForceClear
 	qStatus
 	qDetail
+	qLeased
+	qLeaseUntil
+	qNotify
+	qPostpone
+	qExpire
+	qAddAttempt
+	qAttempts
+	qPruneAttempts
+	qDeleteAttempts
+	qCount
+	qFailed
+	qRequeue
+	qGroup
+	qItem
 	qend
 )
 
-func (d *drv) createSql(drvCnt, maxThread int) {
+// columns of model.Item, see scanItem
+const itemCols = `notify_id, driver,
+  endpoint, content,
+  create_at, next_at,
+  tried, cur_state,
+  priority, send_at,
+  expire_at, max_tries,
+  backoff, backoff_base,
+  backoff_cap, callback,
+  group_id, base_tries,
+  resend`
+
+// conditions of types.Filter, uses $1 ~ $4
+const pgFilter = `($1::text='' OR driver=$1)
+  AND ($2::text='' OR endpoint=$2)
+  AND ($3::bigint=0 OR create_at>=$3)
+  AND ($4::bigint=0 OR create_at<$4)`
+
+func (d *drv) createSql() {
 	d.stmts[qCreate] = `INSERT INTO items
-  (notify_id,driver,endpoint,content,create_at,next_at,tried)
+  (notify_id,driver,endpoint,content,create_at,next_at,tried,priority,send_at,expire_at,
+   max_tries,backoff,backoff_base,backoff_cap,
+   callback,group_id)
 VALUES
-  ($1,$2,$3,$4,$5,$6,$7)`
-	d.stmts[qDelete] = `DELETE FROM items WHERE notify_id=$1`
-	d.stmts[qResend] = `UPDATE items SET tried=$1, cur_state=0 WHERE notify_id=$2`
+  ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`
+	d.stmts[qDelete] = `DELETE FROM items WHERE notify_id=$1 AND lease_until<=$2`
+	d.stmts[qNotify] = `SELECT pg_notify('` + channel + `', '')`
+	d.stmts[qLeased] = `SELECT COUNT(*) FROM items WHERE notify_id=$1 AND lease_until>$2`
+	d.stmts[qLeaseUntil] = `SELECT lease_until FROM items WHERE notify_id=$1`
+	// max_tries is kept, so requeueing it later uses original retry policy.
+	// Lease is cleared so journaled result of previous try cannot overwrite
+	// it.
+	d.stmts[qResend] = `UPDATE items SET
+  base_tries=tried, resend=true, cur_state=0,
+  lease_owner='', lease_until=0
+WHERE notify_id=$1 AND lease_until<=$2`
 	d.stmts[qResult] = `SELECT response FROM items WHERE notify_id=$1 LIMIT 1`
 	d.stmts[qUpdate] = `UPDATE items SET
-  tried=$1, next_at=$2, cur_state=$3, response=$4
-WHERE notify_id=$5`
-	d.stmts[qStatus] = `SELECT create_at, next_at, tried, cur_state FROM items WHERE notify_id=$1`
-	d.stmts[qDetail] = `SELECT driver, endpoint, content, response, create_at, next_at, tried, cur_state FROM items WHERE notify_id=$1`
-
-	drvStr := genvar(3, drvCnt)
-	curStr := genvar(3+drvCnt, maxThread)
-	d.stmts[qPending] = fmt.Sprintf(`SELECT
-  notify_id, driver,
-  endpoint, content,
-  create_at, next_at,
-  tried, cur_state
+  tried=$1, next_at=$2, cur_state=$3, response=$4,
+  lease_owner='', lease_until=0
+WHERE notify_id=$5 AND lease_owner=$6`
+	d.stmts[qPostpone] = `UPDATE items SET
+  next_at=$1, lease_owner='', lease_until=0
+WHERE notify_id=$2 AND lease_owner=$3`
+	d.stmts[qExpire] = `UPDATE items SET
+  next_at=$1, cur_state=$2, lease_owner='', lease_until=0
+WHERE notify_id=$3 AND lease_owner=$4`
+	d.stmts[qAddAttempt] = `INSERT INTO attempts
+  (notify_id,tried,exec_at,duration,error,response,thread)
+VALUES
+  ($1,$2,$3,$4,$5,$6,$7)`
+	d.stmts[qAttempts] = `SELECT tried, exec_at, duration, error, response, thread
+FROM attempts WHERE notify_id=$1 ORDER BY attempt_id ASC`
+	d.stmts[qPruneAttempts] = `DELETE FROM attempts a
+WHERE NOT EXISTS (SELECT 1 FROM items i WHERE i.notify_id=a.notify_id)`
+	d.stmts[qDeleteAttempts] = `DELETE FROM attempts WHERE notify_id=$1`
+	d.stmts[qFailed] = `SELECT
+  notify_id, driver, endpoint, content, response,
+  create_at, next_at, tried, cur_state, send_at, expire_at
 FROM items
-WHERE cur_state=0
-  AND next_at<=$1
-  AND tried<$2
-  AND driver IN (%s)
-  AND notify_id NOT IN (%s)
-ORDER BY next_at ASC
-LIMIT 1`, drvStr, curStr)
-
-	curStr = genvar(2, maxThread)
-	d.stmts[qClear] = fmt.Sprintf(`DELETE FROM items WHERE create_at < $1 AND cur_state IN (1,2) AND notify_id NOT IN (%s)`, curStr)
-	d.stmts[qForceClear] = fmt.Sprintf(`DELETE FROM items WHERE create_at < $1 AND notify_id NOT IN (%s)`, curStr)
+WHERE cur_state=2 AND ` + pgFilter + `
+ORDER BY create_at ASC, notify_id ASC
+LIMIT $5 OFFSET $6`
+	d.stmts[qRequeue] = `UPDATE items SET
+  next_at=$5, cur_state=0, base_tries=tried, resend=false,
+  lease_owner='', lease_until=0
+WHERE cur_state=2 AND ` + pgFilter
+	d.stmts[qGroup] = `SELECT
+  notify_id, driver, endpoint,
+  create_at, next_at, tried, cur_state, send_at, expire_at
+FROM items WHERE group_id=$1 ORDER BY notify_id ASC`
+	d.stmts[qCount] = `SELECT driver, cur_state, COUNT(*) FROM items GROUP BY driver, cur_state`
+	d.stmts[qStatus] = `SELECT create_at, next_at, tried, cur_state, send_at, expire_at FROM items WHERE notify_id=$1`
+	d.stmts[qItem] = `SELECT ` + itemCols + ` FROM items WHERE notify_id=$1`
+	d.stmts[qDetail] = `SELECT driver, endpoint, content, response, create_at, next_at, tried, cur_state, send_at, expire_at FROM items WHERE notify_id=$1`
+
+	d.stmts[qPending] = fmt.Sprintf(`UPDATE items SET lease_owner=$1, lease_until=$2
+WHERE notify_id IN (
+  SELECT notify_id FROM items
+  WHERE cur_state=0
+    AND next_at<=$3
+    AND tried < base_tries + CASE WHEN resend THEN 1 WHEN max_tries>0 THEN max_tries ELSE $4::bigint END
+    AND lease_until<=$3
+    AND driver = ANY($6)
+  ORDER BY priority + ($3 - next_at) / %d DESC, next_at ASC
+  LIMIT $5
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING `+itemCols, model.PriorityAging)
+
+	d.stmts[qClear] = `DELETE FROM items WHERE create_at < $1 AND cur_state IN (1,2,3) AND lease_until<=$2`
+	d.stmts[qForceClear] = `DELETE FROM items WHERE create_at < $1 AND lease_until<=$2`
 }
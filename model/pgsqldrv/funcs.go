@@ -7,6 +7,8 @@ package pgsqldrv
 import (
 	"database/sql"
 	"errors"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/raohwork/notify/model"
@@ -19,24 +21,75 @@ func (d *drv) Create(i *model.Item) (err error) {
 		i.ID, i.Driver,
 		i.Endpoint, i.Content,
 		i.CreateAt, i.NextAt, i.Tried,
+		i.Priority, i.SendAt, i.ExpireAt,
+		i.Retry.MaxTries, i.Retry.Backoff,
+		i.Retry.Base, i.Retry.Cap,
+		i.Callback, i.Group,
 	)
+	if isDupe(err) {
+		err = &model.E409{}
+	}
+	if err == nil {
+		// wake up other instances, they will find it by polling if failed
+		d.stmt(qNotify).Exec()
+	}
 	return
 }
 
-func (d *drv) Delete(id string, ids []string) (err error) {
-	for _, i := range ids {
-		if id == i {
-			return errors.New("notification is processing, cannot delete")
-		}
-	}
+// isDupe detects unique_violation without depending on specific pg driver
+func isDupe(err error) (ret bool) {
+	var e interface{ SQLState() string }
+	return errors.As(err, &e) && e.SQLState() == "23505"
+}
+
+func (d *drv) Delete(id string) (err error) {
+	now := time.Now().Unix()
 	stmt := d.stmt(qDelete)
-	_, err = stmt.Exec(id)
+	res, err := stmt.Exec(id, now)
+	if err != nil {
+		return
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return
+	}
+	if cnt > 0 {
+		_, err = d.stmt(qDeleteAttempts).Exec(id)
+		return
+	}
+
+	var leased int
+	if err = d.stmt(qLeased).QueryRow(id, now).Scan(&leased); err != nil {
+		return
+	}
+	if leased > 0 {
+		err = &model.E409{}
+	}
 	return
 }
 
-func (d *drv) Resend(id string, max uint32) (err error) {
+func (d *drv) Resend(id string) (err error) {
+	now := time.Now().Unix()
 	stmt := d.stmt(qResend)
-	_, err = stmt.Exec(max-1, id)
+	res, err := stmt.Exec(id, now)
+	if err != nil {
+		return
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil || cnt == 1 {
+		return
+	}
+
+	// tell leased notification from unknown one
+	var until int64
+	err = d.stmt(qLeaseUntil).QueryRow(id).Scan(&until)
+	switch {
+	case err == sql.ErrNoRows:
+		err = &model.E404{}
+	case err == nil && until > now:
+		err = &model.E409{}
+	}
 	return
 }
 
@@ -47,31 +100,53 @@ func (d *drv) Result(id string) (ret []byte, err error) {
 	return
 }
 
-func (d *drv) Update(id string, tried uint32, next int64, state types.State, resp []byte) (err error) {
-	stmt := d.stmt(qUpdate)
-	_, err = stmt.Exec(tried, next, state, resp, id)
+// leased converts result of updating leased notification
+func leased(res sql.Result, e error) (err error) {
+	if err = e; err != nil {
+		return
+	}
+	cnt, err := res.RowsAffected()
+	if err == nil && cnt == 0 {
+		err = &model.ELostLease{}
+	}
 	return
 }
 
-func (d *drv) Clear(t time.Time, cur []string) (err error) {
+func (d *drv) Update(id, lease string, tried uint32, next int64, state types.State, resp []byte) (err error) {
+	stmt := d.stmt(qUpdate)
+	return leased(stmt.Exec(tried, next, state, resp, id, lease))
+}
+
+func (d *drv) Postpone(id, lease string, next int64) (err error) {
+	stmt := d.stmt(qPostpone)
+	return leased(stmt.Exec(next, id, lease))
+}
+
+func (d *drv) Expire(id, lease string, now int64) (err error) {
+	stmt := d.stmt(qExpire)
+	return leased(stmt.Exec(now, types.EXPIRED, id, lease))
+}
+
+func (d *drv) token(owner string) (ret string) {
+	seq := atomic.AddUint64(&d.seq, 1)
+	return owner + "/" + strconv.FormatUint(seq, 36)
+}
+
+func (d *drv) Clear(t time.Time) (err error) {
 	stmt := d.stmt(qClear)
-	args := make([]interface{}, 1, len(cur)+1)
-	args[0] = t.Unix()
-	for _, id := range cur {
-		args = append(args, id)
+	if _, err = stmt.Exec(t.Unix(), time.Now().Unix()); err != nil {
+		return
 	}
-	_, err = stmt.Exec(args...)
+	_, err = d.stmt(qPruneAttempts).Exec()
 	return
 }
 
-func (d *drv) ForceClear(t time.Time, cur []string) (err error) {
+func (d *drv) ForceClear(t time.Time) (err error) {
 	stmt := d.stmt(qForceClear)
-	args := make([]interface{}, 1, len(cur)+1)
-	args[0] = t.Unix()
-	for _, id := range cur {
-		args = append(args, id)
+	if _, err = stmt.Exec(t.Unix(), time.Now().Unix()); err != nil {
+		return
 	}
-	_, err = stmt.Exec(args...)
+	_, err = d.stmt(qPruneAttempts).Exec()
 	return
 }
 
@@ -81,6 +156,8 @@ func (d *drv) Status(id string) (ret types.Status, err error) {
 		next   int64
 		try    uint32
 		state  int
+		at     int64
+		expire int64
 	)
 
 	stmt := d.stmt(qStatus)
@@ -90,7 +167,12 @@ func (d *drv) Status(id string) (ret types.Status, err error) {
 		&next,
 		&try,
 		&state,
+		&at,
+		&expire,
 	)
+	if err == sql.ErrNoRows {
+		err = &model.E404{}
+	}
 	if err != nil {
 		return
 	}
@@ -100,6 +182,8 @@ func (d *drv) Status(id string) (ret types.Status, err error) {
 		NextAt:   next,
 		Tried:    try,
 		State:    types.State(state),
+		SendAt:   at,
+		ExpireAt: expire,
 	}
 	return
 }
@@ -114,6 +198,8 @@ func (d *drv) Detail(id string) (ret types.Detail, err error) {
 		next   int64
 		try    uint32
 		state  int
+		at     int64
+		expire int64
 	)
 
 	stmt := d.stmt(qDetail)
@@ -127,11 +213,13 @@ func (d *drv) Detail(id string) (ret types.Detail, err error) {
 		&next,
 		&try,
 		&state,
+		&at,
+		&expire,
 	)
+	if err == sql.ErrNoRows {
+		err = &model.E404{}
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			err = nil
-		}
 		return
 	}
 
@@ -145,64 +233,215 @@ func (d *drv) Detail(id string) (ret types.Detail, err error) {
 			NextAt:   next,
 			Tried:    try,
 			State:    types.State(state),
+			SendAt:   at,
+			ExpireAt: expire,
 		},
 	}
 	return
 }
 
-func (d *drv) Pending(now int64, max uint32, drvs, ids []string) (ret *model.Item, err error) {
+// scanItem reads a row selected with itemCols
+func scanItem(row interface{ Scan(...interface{}) error }) (ret *model.Item, err error) {
 	var (
-		id     string
-		drv    string
-		ep     string
-		c      []byte
-		create int64
-		next   int64
-		try    uint32
-		state  int
+		i     model.Item
+		state int
 	)
+	err = row.Scan(
+		&i.ID,
+		&i.Driver,
+		&i.Endpoint,
+		&i.Content,
+		&i.CreateAt,
+		&i.NextAt,
+		&i.Tried,
+		&state,
+		&i.Priority,
+		&i.SendAt,
+		&i.ExpireAt,
+		&i.Retry.MaxTries,
+		&i.Retry.Backoff,
+		&i.Retry.Base,
+		&i.Retry.Cap,
+		&i.Callback,
+		&i.Group,
+		&i.BaseTries,
+		&i.Resend,
+	)
+	if err != nil {
+		return
+	}
+
+	i.State = types.State(state)
+	return &i, nil
+}
 
-	params := make([]interface{}, 0, len(drvs)+len(ids)+1)
-	params = append(params, now, max)
-	for _, d := range drvs {
-		params = append(params, d)
+func (d *drv) Item(id string) (ret *model.Item, err error) {
+	ret, err = scanItem(d.stmt(qItem).QueryRow(id))
+	if err == sql.ErrNoRows {
+		err = &model.E404{}
 	}
-	for _, d := range ids {
-		params = append(params, d)
+	return
+}
+
+func (d *drv) Pending(now int64, max uint32, drvs []string, owner string, until int64) (ret *model.Item, err error) {
+	arr, err := d.PendingBatch(now, max, drvs, owner, until, 1)
+	if err == nil && len(arr) > 0 {
+		ret = arr[0]
 	}
+	return
+}
 
-	if err != nil {
+func (d *drv) PendingBatch(now int64, max uint32, drvs []string, owner string, until int64, n int) (ret []*model.Item, err error) {
+	if len(drvs) == 0 {
 		return
 	}
 
+	// drvs is sent as text[], so it works with any number of drivers
+	token := d.token(owner)
 	stmt := d.stmt(qPending)
-	row := stmt.QueryRow(params...)
-	err = row.Scan(
-		&id,
-		&drv,
-		&ep,
-		&c,
-		&create,
-		&next,
-		&try,
-		&state,
+	rows, err := stmt.Query(token, until, now, max, n, drvs)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i *model.Item
+		if i, err = scanItem(rows); err != nil {
+			return
+		}
+		i.Lease = token
+		ret = append(ret, i)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// RETURNING does not keep the order of subquery
+	model.SortPending(ret, now)
+	return
+}
+
+func (d *drv) AddAttempt(id string, a types.Attempt) (err error) {
+	stmt := d.stmt(qAddAttempt)
+	_, err = stmt.Exec(
+		id, a.Tried,
+		a.At, a.Duration,
+		a.Error, a.Response,
+		a.Thread,
 	)
+	return
+}
+
+func (d *drv) Attempts(id string) (ret []types.Attempt, err error) {
+	rows, err := d.stmt(qAttempts).Query(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			err = nil
+		return
+	}
+	defer rows.Close()
+
+	ret = []types.Attempt{}
+	for rows.Next() {
+		var a types.Attempt
+		err = rows.Scan(
+			&a.Tried,
+			&a.At,
+			&a.Duration,
+			&a.Error,
+			&a.Response,
+			&a.Thread,
+		)
+		if err != nil {
+			return
 		}
+		ret = append(ret, a)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (d *drv) Count() (ret []model.Count, err error) {
+	rows, err := d.stmt(qCount).Query()
+	if err != nil {
 		return
 	}
+	defer rows.Close()
 
-	ret = &model.Item{
-		ID:       id,
-		Driver:   drv,
-		Endpoint: ep,
-		Content:  c,
-		CreateAt: create,
-		NextAt:   next,
-		Tried:    try,
-		State:    types.State(state),
+	ret = []model.Count{}
+	for rows.Next() {
+		var c model.Count
+		if err = rows.Scan(&c.Driver, &c.State, &c.Count); err != nil {
+			return
+		}
+		ret = append(ret, c)
 	}
+
+	err = rows.Err()
+	return
+}
+
+func (d *drv) Failed(f types.Filter) (ret []types.Failed, err error) {
+	rows, err := d.stmt(qFailed).Query(
+		f.Driver, f.Endpoint,
+		int64(f.After), int64(f.Before),
+		f.Limit, f.Offset,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []types.Failed{}
+	for rows.Next() {
+		var x types.Failed
+		err = rows.Scan(
+			&x.ID, &x.Driver, &x.Endpoint, &x.Content, &x.Response,
+			&x.CreateAt, &x.NextAt, &x.Tried, &x.State, &x.SendAt, &x.ExpireAt,
+		)
+		if err != nil {
+			return
+		}
+		ret = append(ret, x)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (d *drv) Requeue(f types.Filter, now int64) (ret int64, err error) {
+	res, err := d.stmt(qRequeue).Exec(
+		f.Driver, f.Endpoint,
+		int64(f.After), int64(f.Before),
+		now,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
+
+func (d *drv) Group(id string) (ret []types.GroupMember, err error) {
+	rows, err := d.stmt(qGroup).Query(id)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	ret = []types.GroupMember{}
+	for rows.Next() {
+		var x types.GroupMember
+		err = rows.Scan(
+			&x.ID, &x.Driver, &x.Endpoint,
+			&x.CreateAt, &x.NextAt, &x.Tried, &x.State, &x.SendAt, &x.ExpireAt,
+		)
+		if err != nil {
+			return
+		}
+		ret = append(ret, x)
+	}
+
+	err = rows.Err()
 	return
 }
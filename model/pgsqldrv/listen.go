@@ -0,0 +1,50 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package pgsqldrv
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/raohwork/notify/model"
+)
+
+// channel used in LISTEN/NOTIFY
+const channel = "notify_items"
+
+// Listen implements model.Notifier using LISTEN/NOTIFY, it works only with
+// github.com/jackc/pgx/v4/stdlib, and returns &model.ENotSupported{} with
+// other drivers.
+func (d *drv) Listen(ctx context.Context, wake func()) (err error) {
+	c, err := d.DB.Conn(ctx)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	err = c.Raw(func(dc interface{}) (err error) {
+		x, ok := dc.(*stdlib.Conn)
+		if !ok {
+			return &model.ENotSupported{}
+		}
+		conn := x.Conn()
+
+		if _, err = conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return
+		}
+		for {
+			if _, err = conn.WaitForNotification(ctx); err != nil {
+				// the connection is still listening, drop it
+				return driver.ErrBadConn
+			}
+			wake()
+		}
+	})
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return
+}
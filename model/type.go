@@ -6,6 +6,8 @@
 package model
 
 import (
+	"sort"
+
 	"github.com/raohwork/notify/types"
 )
 
@@ -18,4 +20,53 @@ type Item struct {
 	NextAt   int64
 	Tried    uint32
 	State    types.State
+	Priority int
+	SendAt   int64
+	ExpireAt int64 // 0 means never expires
+	Retry    types.RetryPolicy
+	Callback []byte // json encoded types.Callback, nil if not set
+	Group    string // id of broadcast group, empty if not broadcasted
+	Lease    string // lease token set by DBDrv.Pending, see DBDrv.Update
+	// tries before DBDrv.Resend, retry budget counts from here
+	BaseTries uint32
+	// set by DBDrv.Resend, retry budget is exactly one try
+	Resend bool
+}
+
+// Used returns number of tries in current retry budget
+func (i *Item) Used() (ret uint32) {
+	return i.Tried - i.BaseTries
+}
+
+// Count is number of notifications of a driver in specific state
+type Count struct {
+	Driver string
+	State  types.State
+	Count  uint64
+}
+
+// PriorityAging is how long (in seconds) a pending notification has to wait
+// to gain one extra priority, so notifications with lower priority will not
+// starve when there are always higher ones.
+const PriorityAging = 60
+
+// Rank computes the effective priority of a pending notification at now,
+// Pending serves notifications with higher rank first.
+func (i *Item) Rank(now int64) (ret int64) {
+	ret = int64(i.Priority)
+	if wait := now - i.NextAt; wait > 0 {
+		ret += wait / PriorityAging
+	}
+	return
+}
+
+// SortPending sorts notifications in the order Pending serves them.
+func SortPending(items []*Item, now int64) {
+	sort.SliceStable(items, func(i, j int) bool {
+		ri, rj := items[i].Rank(now), items[j].Rank(now)
+		if ri != rj {
+			return ri > rj
+		}
+		return items[i].NextAt < items[j].NextAt
+	})
 }
@@ -0,0 +1,97 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/raohwork/notify/types"
+)
+
+// pauser tracks paused driver types, empty type means all drivers. It also
+// quarantines (pauses) drivers which keep panicking.
+type pauser struct {
+	all    bool
+	drvs   map[string]bool
+	panics map[string]uint32 // consecutive panics
+	sync.RWMutex
+}
+
+func newPauser() (ret *pauser) {
+	return &pauser{
+		drvs:   map[string]bool{},
+		panics: map[string]uint32{},
+	}
+}
+
+// panicked counts a panic of typ, and pauses it if it panics threshold times
+// in a row. It returns true if typ is paused by this call.
+func (p *pauser) panicked(typ string, threshold uint32) (ret bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.panics[typ]++
+	if p.panics[typ] < threshold || p.drvs[typ] {
+		return
+	}
+	p.drvs[typ] = true
+	return true
+}
+
+// sent resets panic counter of typ
+func (p *pauser) sent(typ string) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.panics, typ)
+}
+
+func (p *pauser) pause(typ string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if typ == "" {
+		p.all = true
+		return
+	}
+	p.drvs[typ] = true
+}
+
+// resume resumes typ, or every driver if typ is empty. Resuming single driver
+// has no effect while all drivers are paused.
+func (p *pauser) resume(typ string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if typ == "" {
+		p.all = false
+		p.drvs = map[string]bool{}
+		p.panics = map[string]uint32{}
+		return
+	}
+	delete(p.drvs, typ)
+	delete(p.panics, typ)
+}
+
+func (p *pauser) paused(typ string) (ret bool) {
+	p.RLock()
+	defer p.RUnlock()
+
+	return p.all || p.drvs[typ]
+}
+
+func (p *pauser) status() (ret types.PauseStatus) {
+	p.RLock()
+	defer p.RUnlock()
+
+	ret.All = p.all
+	ret.Drivers = make([]string, 0, len(p.drvs))
+	for k := range p.drvs {
+		ret.Drivers = append(ret.Drivers, k)
+	}
+	sort.Strings(ret.Drivers)
+	return
+}
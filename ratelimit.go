@@ -0,0 +1,167 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit defines a token bucket to limit sending rate
+type RateLimit struct {
+	// tokens added per second
+	Rate float64
+	// size of the bucket, which is max number of notifications can be sent
+	// at once. 0 is treated as 1.
+	Burst uint32
+}
+
+// ParseRateLimits parses rate limit settings in "driver=rate[:burst],..." format
+//
+// For example, "TGMarkdown=30,SMSAV8D=0.5:10" limits TGMarkdown at 30/s and
+// SMSAV8D at 1 message per 2 seconds with burst of 10 messages.
+func ParseRateLimits(str string) (ret map[string]RateLimit, err error) {
+	ret = map[string]RateLimit{}
+	for _, line := range strings.Split(str, ",") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		arr := strings.SplitN(line, "=", 2)
+		if len(arr) != 2 || strings.TrimSpace(arr[0]) == "" {
+			return nil, errors.New("invalid rate limit: " + line)
+		}
+		vals := strings.SplitN(arr[1], ":", 2)
+
+		var l RateLimit
+		l.Rate, err = strconv.ParseFloat(strings.TrimSpace(vals[0]), 64)
+		if err != nil || l.Rate <= 0 {
+			return nil, errors.New("invalid rate limit: " + line)
+		}
+		if len(vals) == 2 {
+			b, e := strconv.ParseUint(strings.TrimSpace(vals[1]), 10, 32)
+			if e != nil {
+				return nil, errors.New("invalid rate limit: " + line)
+			}
+			l.Burst = uint32(b)
+		}
+
+		ret[strings.TrimSpace(arr[0])] = l
+	}
+
+	return
+}
+
+func (l RateLimit) size() (ret float64) {
+	if ret = float64(l.Burst); ret < 1 {
+		ret = 1
+	}
+	return
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// fill adds tokens since last time, returns how long to wait for next token
+func (b *bucket) fill(l RateLimit, now time.Time) (wait time.Duration) {
+	max := l.size()
+	if b.tokens += now.Sub(b.last).Seconds() * l.Rate; b.tokens > max {
+		b.tokens = max
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		return
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// limiter is not thread-safe, it is used only in worker.mainloop
+type limiter struct {
+	drv     map[string]RateLimit
+	ep      map[string]RateLimit
+	buckets map[string]*bucket
+}
+
+func newLimiter(drv, ep map[string]RateLimit) (ret *limiter) {
+	return &limiter{
+		drv:     drv,
+		ep:      ep,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// max number of buckets before removing idle ones
+const maxBuckets = 1024
+
+func (l *limiter) bucket(key string, x RateLimit, now time.Time) (ret *bucket) {
+	ret, ok := l.buckets[key]
+	if ok {
+		return
+	}
+
+	if len(l.buckets) >= maxBuckets {
+		l.sweep(now)
+	}
+	// new bucket is full
+	ret = &bucket{tokens: x.size(), last: now}
+	l.buckets[key] = ret
+	return
+}
+
+// sweep removes buckets which are full, as they are same as new one
+func (l *limiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		typ := strings.SplitN(k, "\n", 2)
+		x, ok := l.ep[typ[0]]
+		if len(typ) == 1 {
+			x, ok = l.drv[typ[0]]
+		}
+		if !ok || (b.fill(x, now) == 0 && b.tokens >= x.size()) {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// blocked reports if there's no token left for whole driver
+func (l *limiter) blocked(typ string, now time.Time) (ret bool) {
+	x, ok := l.drv[typ]
+	if !ok {
+		return
+	}
+
+	return l.bucket(typ, x, now).fill(x, now) > 0
+}
+
+// take consumes a token from driver and endpoint buckets, returns how long to
+// wait if any of them is empty. Nothing is consumed in that case.
+func (l *limiter) take(typ, ep string, now time.Time) (wait time.Duration) {
+	var arr []*bucket
+	if x, ok := l.drv[typ]; ok {
+		b := l.bucket(typ, x, now)
+		wait = b.fill(x, now)
+		arr = append(arr, b)
+	}
+	if x, ok := l.ep[typ]; ok {
+		b := l.bucket(typ+"\n"+ep, x, now)
+		if w := b.fill(x, now); w > wait {
+			wait = w
+		}
+		arr = append(arr, b)
+	}
+	if wait > 0 {
+		return
+	}
+
+	for _, b := range arr {
+		b.tokens--
+	}
+	return
+}
@@ -0,0 +1,58 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+func checkPolicy(p *types.RetryPolicy) (err error) {
+	switch p.Backoff {
+	case "", types.BackoffFixed, types.BackoffLinear, types.BackoffExponential:
+	default:
+		return errors.New("unsupported backoff: " + p.Backoff)
+	}
+	if p.Cap > 0 && p.Cap < p.Base {
+		err = errors.New("cap of backoff is less than base")
+	}
+	return
+}
+
+// backoff computes time of next try using p, tried is number of tries before
+// lastExec. p.Backoff *MUST NOT* be empty.
+func backoff(p types.RetryPolicy, lastExec time.Time, tried uint32) (next time.Time) {
+	base := int64(p.Base)
+	if base == 0 {
+		base = 60
+	}
+
+	delay := base
+	switch p.Backoff {
+	case types.BackoffLinear:
+		if n := int64(tried) + 1; n > maxDelay/base {
+			delay = maxDelay
+		} else {
+			delay = base * n
+		}
+	case types.BackoffExponential:
+		if tried > 30 || base<<tried > maxDelay {
+			delay = maxDelay
+		} else {
+			delay = base << tried
+		}
+	}
+	if p.Cap > 0 && delay > int64(p.Cap) {
+		delay = int64(p.Cap)
+	}
+
+	return lastExec.Add(time.Duration(delay) * time.Second)
+}
+
+// max delay in seconds which can be converted to time.Duration
+const maxDelay = math.MaxInt64 / int64(time.Second)
@@ -0,0 +1,32 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package notify
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+func TestBackoffLargeTried(t *testing.T) {
+	now := time.Now()
+	for _, typ := range []string{types.BackoffLinear, types.BackoffExponential} {
+		for _, tried := range []uint32{28, 31, 64, math.MaxUint32 - 1} {
+			for _, base := range []uint32{0, 60, math.MaxUint32} {
+				p := types.RetryPolicy{Backoff: typ, Base: base}
+				if next := backoff(p, now, tried); !next.After(now) {
+					t.Errorf("%s with base %d at %d tries: next %v is not after now", typ, base, tried, next)
+				}
+			}
+		}
+	}
+
+	p := types.RetryPolicy{Backoff: types.BackoffExponential, Cap: 3600}
+	if next := backoff(p, now, 64); !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected capped at 1 hour, got %v", next.Sub(now))
+	}
+}
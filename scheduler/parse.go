@@ -0,0 +1,132 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package scheduler
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+func parseDurations(str string) (ret []time.Duration, err error) {
+	arr := strings.Split(str, ",")
+	ret = make([]time.Duration, 0, len(arr))
+	for _, s := range arr {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			// zero delay retries in a hot loop
+			return nil, errors.New("non-positive duration: " + s)
+		}
+		ret = append(ret, d)
+	}
+
+	return
+}
+
+var jitters = map[string]Jitter{
+	"":             NoJitter,
+	"none":         NoJitter,
+	"full":         FullJitter,
+	"decorrelated": DecorrelatedJitter,
+}
+
+// Parse creates a Scheduler from spec, which is one of
+//
+//   - fixed:DELAY                e.g. "fixed:1m"
+//   - exp:BASE[,CAP[,JITTER]]    e.g. "exp:1m,2h,full"
+//   - steps:DELAY,DELAY,...      e.g. "steps:1m,5m,30m,2h"
+//
+// JITTER can be none, full or decorrelated. Durations are in
+// time.ParseDuration format and must be positive. Empty spec returns nil, which
+// is DefaultScheduler in SenderOptions.
+func Parse(spec string) (ret types.Scheduler, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return
+	}
+
+	arr := strings.SplitN(spec, ":", 2)
+	if len(arr) != 2 {
+		return nil, errors.New("invalid scheduler: " + spec)
+	}
+	kind, args := arr[0], strings.Split(arr[1], ",")
+
+	switch kind {
+	case "fixed":
+		d, err := parseDurations(arr[1])
+		if err != nil || len(d) != 1 {
+			return nil, errors.New("invalid fixed scheduler: " + spec)
+		}
+		ret = Fixed(d[0])
+	case "exp":
+		var j Jitter
+		if len(args) == 3 {
+			x, ok := jitters[strings.TrimSpace(args[2])]
+			if !ok {
+				return nil, errors.New("unknown jitter: " + args[2])
+			}
+			j = x
+			args = args[:2]
+		}
+		d, err := parseDurations(strings.Join(args, ","))
+		if err != nil || len(d) > 2 {
+			return nil, errors.New("invalid exp scheduler: " + spec)
+		}
+		d = append(d, 0) // cap defaults to no limit
+		ret = Exponential(d[0], d[1], j)
+	case "steps":
+		d, err := parseDurations(arr[1])
+		if err != nil {
+			return nil, errors.New("invalid steps scheduler: " + spec)
+		}
+		ret = Steps(d...)
+	default:
+		return nil, errors.New("unknown scheduler: " + kind)
+	}
+
+	return
+}
+
+// ParseMux creates a Mux. def is spec of default scheduler, and drivers is list
+// of DRIVER=SPEC separated by semicolon, like "TGMarkdown=fixed:30s;SMTP=exp:1m".
+// It returns nil if both are empty.
+func ParseMux(def, drivers string) (ret types.Scheduler, err error) {
+	d, err := Parse(def)
+	if err != nil {
+		return
+	}
+	if strings.TrimSpace(drivers) == "" {
+		return d, nil
+	}
+	if d == nil {
+		return nil, errors.New("default scheduler is required")
+	}
+
+	m := map[string]types.Scheduler{}
+	for _, str := range strings.Split(drivers, ";") {
+		if str = strings.TrimSpace(str); str == "" {
+			continue
+		}
+		arr := strings.SplitN(str, "=", 2)
+		if len(arr) != 2 || arr[0] == "" {
+			return nil, errors.New("invalid driver scheduler: " + str)
+		}
+		s, err := Parse(arr[1])
+		if err != nil {
+			return nil, err
+		}
+		if s == nil {
+			return nil, errors.New("empty scheduler of driver " + arr[0])
+		}
+		m[strings.TrimSpace(arr[0])] = s
+	}
+
+	return Mux(d, m), nil
+}
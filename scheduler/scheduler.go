@@ -0,0 +1,109 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+// Package scheduler provides common types.Scheduler implementations
+package scheduler
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+// Jitter defines how Exponential randomizes the delay
+type Jitter int
+
+const (
+	// NoJitter waits exactly base * 2^tried
+	NoJitter Jitter = iota
+	// FullJitter waits random time between 0 and base * 2^tried
+	FullJitter
+	// DecorrelatedJitter waits random time between base and base * 3^tried.
+	//
+	// It is a stateless variant of "decorrelated jitter" described in
+	// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+	// as Scheduler does not know previous delay.
+	DecorrelatedJitter
+)
+
+// max delay to prevent overflow
+const maxDelay = time.Duration(1<<62 - 1)
+
+// grow computes base * factor^tried, capped by cap (0 = maxDelay)
+func grow(base, cap time.Duration, factor int64, tried uint32) (ret time.Duration) {
+	if base <= 0 {
+		return 0
+	}
+	if cap <= 0 {
+		cap = maxDelay
+	}
+
+	ret = base
+	for i := uint32(0); i < tried && ret < cap; i++ {
+		if ret > cap/time.Duration(factor) {
+			return cap
+		}
+		ret *= time.Duration(factor)
+	}
+	if ret > cap {
+		ret = cap
+	}
+	return
+}
+
+// between returns random duration in [min, max]
+func between(min, max time.Duration) (ret time.Duration) {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)+1))
+}
+
+// Exponential creates a Scheduler which doubles the delay every try, beginning
+// with base and never exceeds cap. cap = 0 means no limit.
+func Exponential(base, cap time.Duration, j Jitter) (ret types.Scheduler) {
+	return func(driver, notifyID string, lastExec time.Time, tried uint32) (next time.Time, stop bool) {
+		var delay time.Duration
+		switch j {
+		case FullJitter:
+			delay = between(0, grow(base, cap, 2, tried))
+		case DecorrelatedJitter:
+			delay = between(base, grow(base, cap, 3, tried))
+		default:
+			delay = grow(base, cap, 2, tried)
+		}
+
+		return lastExec.Add(delay), false
+	}
+}
+
+// Fixed creates a Scheduler which always waits d
+func Fixed(d time.Duration) (ret types.Scheduler) {
+	return func(driver, notifyID string, lastExec time.Time, tried uint32) (next time.Time, stop bool) {
+		return lastExec.Add(d), false
+	}
+}
+
+// Steps creates a Scheduler which waits steps[tried], and stops retrying after
+// the last step.
+func Steps(steps ...time.Duration) (ret types.Scheduler) {
+	return func(driver, notifyID string, lastExec time.Time, tried uint32) (next time.Time, stop bool) {
+		if int(tried) >= len(steps) {
+			return lastExec, true
+		}
+		return lastExec.Add(steps[tried]), false
+	}
+}
+
+// Mux creates a Scheduler which delegates to m[driver], or def if not found.
+func Mux(def types.Scheduler, m map[string]types.Scheduler) (ret types.Scheduler) {
+	return func(driver, notifyID string, lastExec time.Time, tried uint32) (next time.Time, stop bool) {
+		s, ok := m[driver]
+		if !ok {
+			s = def
+		}
+		return s(driver, notifyID, lastExec, tried)
+	}
+}
@@ -0,0 +1,117 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/raohwork/notify/types"
+)
+
+func delay(s types.Scheduler, driver string, tried uint32) (ret time.Duration, stop bool) {
+	now := time.Now()
+	next, stop := s(driver, "id", now, tried)
+	return next.Sub(now), stop
+}
+
+func TestExponential(t *testing.T) {
+	s := Exponential(time.Second, time.Minute, NoJitter)
+	expect := []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		16 * time.Second, 32 * time.Second, time.Minute, time.Minute,
+	}
+	for tried, e := range expect {
+		if d, _ := delay(s, "", uint32(tried)); d != e {
+			t.Errorf("expected %s at try #%d, got %s", e, tried, d)
+		}
+	}
+
+	if d, _ := delay(s, "", 1<<31); d != time.Minute {
+		t.Errorf("expected to be capped, got %s", d)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	full := Exponential(time.Second, time.Minute, FullJitter)
+	dec := Exponential(time.Second, time.Minute, DecorrelatedJitter)
+	for i := 0; i < 100; i++ {
+		if d, _ := delay(full, "", 3); d < 0 || d > 8*time.Second {
+			t.Fatalf("full jitter out of range: %s", d)
+		}
+		if d, _ := delay(dec, "", 3); d < time.Second || d > 27*time.Second {
+			t.Fatalf("decorrelated jitter out of range: %s", d)
+		}
+		if d, _ := delay(dec, "", 10); d < time.Second || d > time.Minute {
+			t.Fatalf("decorrelated jitter is not capped: %s", d)
+		}
+	}
+}
+
+func TestSteps(t *testing.T) {
+	s, err := Parse("steps:1m,5m,30m,2h")
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	expect := []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour}
+	for tried, e := range expect {
+		if d, stop := delay(s, "", uint32(tried)); stop || d != e {
+			t.Errorf("expected %s at try #%d, got %s (stop: %v)", e, tried, d, stop)
+		}
+	}
+	if _, stop := delay(s, "", 4); !stop {
+		t.Error("expected to stop after last step")
+	}
+}
+
+func TestParseMux(t *testing.T) {
+	s, err := ParseMux("fixed:1m", "A=exp:1s,1m,none; B=fixed:5s")
+	if err != nil {
+		t.Fatal("unexpected error: ", err)
+	}
+
+	cases := []struct {
+		driver string
+		tried  uint32
+		expect time.Duration
+	}{
+		{"A", 2, 4 * time.Second},
+		{"B", 2, 5 * time.Second},
+		{"C", 2, time.Minute},
+	}
+	for _, c := range cases {
+		if d, _ := delay(s, c.driver, c.tried); d != c.expect {
+			t.Errorf("expected %s for %s, got %s", c.expect, c.driver, d)
+		}
+	}
+}
+
+func TestParseError(t *testing.T) {
+	bad := []string{
+		"fixed",
+		"fixed:1m,2m",
+		"exp:1m,2h,half",
+		"exp:1m,2m,3m,full",
+		"steps:1m,-5m",
+		"fixed:0s",
+		"exp:0s",
+		"exp:1m,0s",
+		"steps:0s,1m",
+		"random:1m",
+	}
+	for _, spec := range bad {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error for %s", spec)
+		}
+	}
+
+	if s, err := Parse(""); s != nil || err != nil {
+		t.Errorf("expected nil for empty spec, got %v", err)
+	}
+	if _, err := ParseMux("", "A=fixed:1m"); err == nil {
+		t.Error("expected error without default scheduler")
+	}
+}
@@ -6,9 +6,15 @@ package notify
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"io"
 	"math"
+	"net/http"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/raohwork/notify/model"
@@ -17,14 +23,26 @@ import (
 
 type sender interface {
 	Register(types.Driver)
+	Unregister(typ string)
 	Start()
 	Stop(ctx context.Context)
 
 	drivers() (ret []string)
 	driver(typ string) (ret types.Driver, ok bool)
 	maxThreads() (ret uint16)
-	maxRetry() (ret uint32)
-	curID() (ret []string)
+	breakers() (ret []types.BreakerStatus)
+	// writes metrics of sender in prometheus text format
+	writeMetrics(w io.Writer)
+	// stops claiming notifications of driver typ, or all drivers if typ
+	// is empty
+	pause(typ string)
+	// reverts pause
+	resume(typ string)
+	paused() (ret types.PauseStatus)
+	// reports whether mainloop is running
+	alive() (ret bool)
+	// wakes up the worker to check new notifications immediately
+	wakeup()
 }
 
 // SenderOptions defines configurations of internal worker
@@ -35,9 +53,60 @@ type SenderOptions struct {
 	MaxTries uint32
 	// user provided scheduler. nil uses DefaultScheduler
 	Scheduler types.Scheduler
+	// deadline of sending a notification, 0 = no limit. It works only with
+	// drivers implementing types.ContextDriver.
+	SendTimeout time.Duration
+	// overrides SendTimeout for specific driver type
+	SendTimeouts map[string]time.Duration
+	// limits sending rate of specific driver type. Notifications exceeding
+	// the limit are postponed, not counted as a try.
+	RateLimits map[string]RateLimit
+	// same as RateLimits, but limits each endpoint of the driver separately
+	EndpointRateLimits map[string]RateLimit
+	// skips endpoints which keep failing for a while
+	Breaker BreakerOptions
+	// pauses driver which panics this many times in a row, until it is
+	// resumed by /resume. 0 = 3.
+	QuarantineThreshold uint32
 	// how many goroutines to do the sending job.
 	// 0 will be updated to 1 when creating sender.
 	MaxThreads uint16
+	// worker checks db for notifications to send periodically when idle,
+	// beginning with MinPollInterval and doubling each time until
+	// MaxPollInterval. It resets when a notification is found or created.
+	// 0 = 100ms for MinPollInterval, 5s for MaxPollInterval.
+	MinPollInterval time.Duration
+	MaxPollInterval time.Duration
+	// identifies this instance when claiming notifications from db, so you
+	// can run several instances with same db. It *MUST* be unique across
+	// instances. Empty string is updated to hostname with random suffix.
+	NodeID string
+	// how long a claimed notification is reserved for this instance. Lease
+	// left behind by a crashed instance expires after this, and the
+	// notification will be sent again by others. It *SHOULD* be longer than
+	// any driver takes to send a notification. 0 = 5 minutes.
+	LeaseTime time.Duration
+	// how many times to retry writing result to db after sending, waiting
+	// UpdateBackoff before first retry and doubling each time. 0 = 3 times
+	// and 100ms.
+	UpdateRetries uint32
+	UpdateBackoff time.Duration
+	// path of journal file. Results still cannot be written to db after
+	// retrying are appended to it, and replayed at startup and before
+	// claiming notifications, so a delivered notification will not be sent
+	// again. Empty string disables journaling.
+	Journal string
+	// callbacks on lifecycle events of notifications
+	Hooks
+	// http client to send types.Callback, nil uses a client with 30 seconds
+	// timeout
+	CallbackClient *http.Client
+	// collects sending statistics and exposes them at "/metrics" of
+	// APIServer in prometheus text format
+	Metrics bool
+	// logs allocations, attempts, state transitions and errors. nil
+	// disables logging.
+	Logger Logger
 	// db driver, required
 	model.DBDrv
 }
@@ -56,18 +125,78 @@ func (o *SenderOptions) normalize() (err error) {
 	if o.Scheduler == nil {
 		o.Scheduler = DefaultScheduler
 	}
+	if o.MinPollInterval <= 0 {
+		o.MinPollInterval = 100 * time.Millisecond
+	}
+	if o.MaxPollInterval <= 0 {
+		o.MaxPollInterval = 5 * time.Second
+	}
+	if o.MaxPollInterval < o.MinPollInterval {
+		o.MaxPollInterval = o.MinPollInterval
+	}
+	if o.LeaseTime <= 0 {
+		o.LeaseTime = 5 * time.Minute
+	}
+	if o.NodeID == "" {
+		o.NodeID = nodeID()
+	}
+	if o.QuarantineThreshold == 0 {
+		o.QuarantineThreshold = 3
+	}
+	if o.UpdateRetries == 0 {
+		o.UpdateRetries = 3
+	}
+	if o.UpdateBackoff <= 0 {
+		o.UpdateBackoff = 100 * time.Millisecond
+	}
+	if o.Logger == nil {
+		o.Logger = nopLogger{}
+	}
+	if o.CallbackClient == nil {
+		o.CallbackClient = &http.Client{Timeout: 30 * time.Second}
+	}
 	return
 }
 
+func nodeID() (ret string) {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "notify"
+	}
+
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return host + "-" + hex.EncodeToString(buf)
+}
+
 type worker struct {
 	SenderOptions
 	drvs    map[string]types.Driver
+	drvLock sync.RWMutex
+	// sends callbacks, kept out of drvs so clients cannot use it
+	cb      types.Driver
 	threads chan *thread
 	wg      *sync.WaitGroup
 	ctx     context.Context
 	cancel  context.CancelFunc
-	drvStr  []string
+	abort   context.CancelFunc
 	job     *jobCtrl
+	// prefetched notifications, only accessed in mainloop
+	buf []*model.Item
+	// current polling interval, only accessed in mainloop
+	idle time.Duration
+	wake chan struct{}
+	// only accessed in mainloop
+	limit *limiter
+	br    *breaker
+	m     *metrics
+	// paused drivers, shared with api server
+	pauser  *pauser
+	journal *journal
+	// 1 if mainloop is running, accessed atomically
+	running int32
+	// closed when mainloop returns
+	stopped chan struct{}
 }
 
 // newSender creates a Sender.
@@ -77,11 +206,22 @@ func newSender(opt SenderOptions) (ret sender, err error) {
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
+	sendCtx, abort := context.WithCancel(context.Background())
 
 	wg := &sync.WaitGroup{}
 	wg.Add(int(opt.MaxThreads))
 
 	job := newJobCtl(opt.MaxThreads)
+	br := newBreaker(opt.Breaker)
+	var m *metrics
+	if opt.Metrics {
+		m = newMetrics()
+	}
+	p := newPauser()
+	var j *journal
+	if opt.Journal != "" {
+		j = newJournal(opt.Journal)
+	}
 	threads := make(chan *thread, opt.MaxThreads)
 	for i := uint16(0); i < opt.MaxThreads; i++ {
 		x := &thread{
@@ -90,28 +230,63 @@ func newSender(opt SenderOptions) (ret sender, err error) {
 			wg:            wg,
 			id:            i,
 			job:           job,
+			ctx:           sendCtx,
+			br:            br,
+			m:             m,
+			journal:       j,
+			pauser:        p,
 		}
 
 		go x.mainloop()
 		threads <- x
 	}
 
-	return &worker{
+	w := &worker{
 		SenderOptions: opt,
 		drvs:          map[string]types.Driver{},
+		cb:            &cbDrv{cl: opt.CallbackClient},
 		threads:       threads,
 		wg:            wg,
 		ctx:           ctx,
 		cancel:        cancel,
+		abort:         abort,
 		job:           job,
-	}, nil
+		wake:          make(chan struct{}, 1),
+		stopped:       make(chan struct{}),
+		limit:         newLimiter(opt.RateLimits, opt.EndpointRateLimits),
+		br:            br,
+		m:             m,
+		pauser:        p,
+		journal:       j,
+	}
+	return w, nil
 }
 
 func (w *worker) Register(drv types.Driver) {
+	if drv.Type() == CallbackDriver {
+		w.Logger.Warn("driver type is reserved, ignored", "driver", drv.Type())
+		return
+	}
+
+	w.drvLock.Lock()
+	defer w.drvLock.Unlock()
+
 	w.drvs[drv.Type()] = drv
+	w.Logger.Debug("registered driver", "driver", drv.Type())
+}
+
+func (w *worker) Unregister(typ string) {
+	w.drvLock.Lock()
+	defer w.drvLock.Unlock()
+
+	delete(w.drvs, typ)
+	w.Logger.Info("unregistered driver", "driver", typ)
 }
 
 func (w *worker) drivers() (ret []string) {
+	w.drvLock.RLock()
+	defer w.drvLock.RUnlock()
+
 	ret = make([]string, 0, len(w.drvs))
 	for k := range w.drvs {
 		ret = append(ret, k)
@@ -120,32 +295,99 @@ func (w *worker) drivers() (ret []string) {
 }
 
 func (w *worker) driver(typ string) (ret types.Driver, ok bool) {
+	w.drvLock.RLock()
+	defer w.drvLock.RUnlock()
+
 	ret, ok = w.drvs[typ]
 	return
 }
 
+// sendDriver is same as driver, but also finds the callback driver
+func (w *worker) sendDriver(typ string) (ret types.Driver, ok bool) {
+	if typ == CallbackDriver {
+		return w.cb, true
+	}
+	return w.driver(typ)
+}
+
 func (w *worker) maxThreads() (ret uint16) {
 	return w.MaxThreads
 }
 
-func (w *worker) maxRetry() (ret uint32) {
-	return w.MaxTries
+func (w *worker) breakers() (ret []types.BreakerStatus) {
+	return w.br.list(time.Now())
+}
+
+func (w *worker) writeMetrics(wr io.Writer) {
+	if w.m != nil {
+		w.m.write(wr)
+	}
+	writeThreads(wr, w.job.list())
 }
 
-func (w *worker) curID() (ret []string) {
-	return w.job.list()
+func (w *worker) pause(typ string) {
+	w.pauser.pause(typ)
+	w.Logger.Info("paused", "driver", typ)
 }
 
-func (w *worker) getDrv(typ string) (ret types.Driver, ok bool) {
-	ret, ok = w.drvs[typ]
+func (w *worker) resume(typ string) {
+	w.pauser.resume(typ)
+	w.Logger.Info("resumed", "driver", typ)
+	w.wakeup()
+}
+
+func (w *worker) paused() (ret types.PauseStatus) {
+	return w.pauser.status()
+}
+
+func (w *worker) alive() (ret bool) {
+	return atomic.LoadInt32(&w.running) == 1
+}
+
+func (w *worker) wakeup() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// available lists drivers which are able to send now
+func (w *worker) available(now time.Time) (ret []string) {
+	drvs := append(w.drivers(), CallbackDriver)
+	ret = make([]string, 0, len(drvs))
+	for _, typ := range drvs {
+		if !w.limit.blocked(typ, now) && !w.pauser.paused(typ) {
+			ret = append(ret, typ)
+		}
+	}
 	return
 }
 
+// alloc returns next notification to send, claiming a batch from db if
+// prefetch buffer is empty. It claims one notification for each idle thread
+// (including the one calling alloc), so one query can keep all threads busy.
 func (w *worker) alloc() (ret *model.Item, err error) {
-	nowt := time.Now()
-	now := nowt.Unix()
-	w.job.Lock()
-	ret, err = w.Pending(now, w.MaxTries, w.drvStr, w.job.rawList())
+	if len(w.buf) == 0 {
+		nowt := time.Now()
+		now := nowt.Unix()
+		until := nowt.Add(w.LeaseTime).Unix()
+		drvs := w.available(nowt)
+		if len(drvs) == 0 {
+			return
+		}
+		n := len(w.threads) + 1
+		w.buf, err = w.PendingBatch(now, w.MaxTries, drvs, w.NodeID, until, n)
+		if err != nil {
+			w.Logger.Error("cannot claim notifications", "error", err)
+			return
+		}
+		if len(w.buf) == 0 {
+			return
+		}
+	}
 
+	ret = w.buf[0]
+	w.buf[0] = nil
+	w.buf = w.buf[1:]
 	return
 }
@@ -7,25 +7,60 @@ package notify
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/raohwork/notify/model"
+	"github.com/raohwork/notify/types"
 	"golang.org/x/net/context"
 )
 
 func (w *worker) Start() {
-	w.drvStr = make([]string, 0, len(w.drvs))
-	for k := range w.drvs {
-		w.drvStr = append(w.drvStr, k)
+	if n, ok := w.DBDrv.(model.Notifier); ok {
+		go w.listen(n)
 	}
 
+	atomic.StoreInt32(&w.running, 1)
+	defer atomic.StoreInt32(&w.running, 0)
+	defer close(w.stopped)
 	w.mainloop()
 }
 
+// listen keeps listening to db until worker is stopped
+func (w *worker) listen(n model.Notifier) {
+	for {
+		err := n.Listen(w.ctx, w.wakeup)
+		if _, ok := err.(*model.ENotSupported); ok {
+			w.Logger.Info("db notification is not supported, polling", "error", err)
+			return
+		}
+		if err != nil && w.ctx.Err() == nil {
+			w.Logger.Warn("lost connection of db notification, polling", "error", err)
+		}
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(w.MaxPollInterval):
+		}
+	}
+}
+
+// Stop stops the worker, and aborts sending notifications if ctx is done
+// before they finish.
 func (w *worker) Stop(ctx context.Context) {
 	w.cancel()
+	if w.alive() {
+		// wait for mainloop to release prefetched notifications
+		select {
+		case <-ctx.Done():
+		case <-w.stopped:
+		}
+	}
 	for i := uint16(0); i < w.MaxThreads; i++ {
 		select {
 		case <-ctx.Done():
+			w.abort()
 			return
 		case x := <-w.threads:
 			close(x.ch)
@@ -40,6 +75,7 @@ func (w *worker) Stop(ctx context.Context) {
 
 	select {
 	case <-ctx.Done():
+		w.abort()
 	case <-ch:
 	}
 
@@ -50,12 +86,13 @@ func (w *worker) mainloop() {
 	for {
 		select {
 		case <-w.ctx.Done():
+			w.release()
 			return
 		case t := <-w.threads:
 			d, err := w.run(t)
 			if err != nil {
+				// logged in w.run()
 				w.threads <- t
-				// TODO: log
 				break
 			}
 			t.ch <- d
@@ -63,41 +100,135 @@ func (w *worker) mainloop() {
 	}
 }
 
-// wait waits a second without blocking w.Stop()
+// release releases prefetched notifications without changing next_at, so
+// other instances can send them without waiting for the lease to expire
+func (w *worker) release() {
+	for _, i := range w.buf {
+		if err := w.Postpone(i.ID, i.Lease, i.NextAt); err != nil {
+			w.Logger.Error("cannot release notification", itemArgs(i, "error", err)...)
+			continue
+		}
+		w.Logger.Debug("released prefetched notification", itemArgs(i)...)
+	}
+	w.buf = nil
+}
+
+// wait waits for a while without blocking w.Stop(), the interval is doubled
+// every time unless being waken up
 func (w *worker) wait() {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
+	if w.idle < w.MinPollInterval {
+		w.idle = w.MinPollInterval
+	}
+	t := time.NewTimer(w.idle)
+	defer t.Stop()
 
 	select {
 	case <-w.ctx.Done():
-	case <-ctx.Done():
+	case <-w.wake:
+		w.idle = 0
+	case <-t.C:
+		if w.idle *= 2; w.idle > w.MaxPollInterval {
+			w.idle = w.MaxPollInterval
+		}
 	}
 }
 
 func (w *worker) run(t *thread) (d *data, err error) {
+	if w.journal.needReplay() {
+		// apply previous results first, or they might be sent again
+		if err = w.journal.replay(w.DBDrv, w.Logger); err != nil {
+			w.Logger.Error("cannot replay journal", "path", w.journal.path, "error", err)
+		}
+		if w.journal.needReplay() {
+			err = errors.New("journal is not fully replayed")
+			w.wait()
+			return
+		}
+	}
+
 	i, err := w.alloc()
 	if err != nil {
+		// prevent flooding db with queries
+		w.wait()
 		return
 	}
 	if i == nil {
-		// nothing to send, wait for a second
-		w.job.Unlock()
+		// nothing to send, wait for a while
 		w.wait()
 		err = errors.New("nothing to send")
 		return
 	}
-	defer w.job.Unlock()
+	w.idle = 0
+	w.Logger.Debug("allocated notification", itemArgs(i, "tried", i.Tried)...)
 
-	drv, ok := w.getDrv(i.Driver)
+	drv, ok := w.sendDriver(i.Driver)
 	if !ok {
-		// this should never happend
-		// TODO: log
+		// prefetched before unregistering, release it without changing
+		// next_at
 		err = fmt.Errorf("got unsupported message: %+v", i)
+		if e := w.Postpone(i.ID, i.Lease, i.NextAt); e != nil {
+			w.Logger.Error("cannot postpone notification", itemArgs(i, "error", e)...)
+		}
+		w.Logger.Warn("unsupported driver", itemArgs(i)...)
+		return
+	}
+
+	if w.pauser.paused(i.Driver) {
+		// prefetched before pausing, release it without changing next_at
+		err = fmt.Errorf("driver %s is paused", i.Driver)
+		if e := w.Postpone(i.ID, i.Lease, i.NextAt); e != nil {
+			w.Logger.Error("cannot postpone notification", itemArgs(i, "error", e)...)
+		}
+		w.Logger.Debug("released by pause", itemArgs(i)...)
+		return
+	}
+
+	now := time.Now()
+	if i.ExpireAt > 0 && i.ExpireAt <= now.Unix() {
+		err = fmt.Errorf("%s is expired at %d", i.ID, i.ExpireAt)
+		w.Logger.Info("state changed", itemArgs(i, "state", types.EXPIRED, "expire_at", i.ExpireAt)...)
+		w.callback(i, types.EXPIRED, nil)
+		if e := w.Expire(i.ID, i.Lease, now.Unix()); e != nil {
+			w.Logger.Error("cannot update notification", itemArgs(i, "error", e)...)
+		}
+		i.State = types.EXPIRED
+		w.finished(i)
+		return
+	}
+
+	key := breakerKey(drv, i.Endpoint)
+	if wait := w.br.allow(key, now); wait > 0 {
+		next := w.postpone(i, now, wait)
+		err = fmt.Errorf("breaker of %s is open until %d", key, next)
+		w.Logger.Info("postponed by circuit breaker", itemArgs(i, "key", key, "next_at", next)...)
+		return
+	}
+	if wait := w.limit.take(i.Driver, i.Endpoint, now); wait > 0 {
+		w.br.cancel(key)
+		next := w.postpone(i, now, wait)
+		err = fmt.Errorf("%s is rate limited until %d", i.ID, next)
+		w.Logger.Debug("postponed by rate limit", itemArgs(i, "next_at", next)...)
 		return
 	}
 
-	d = &data{i: i, drv: drv, ch: w.threads}
-	w.job.set(t.id, i.ID)
+	d = &data{i: i, drv: drv, ch: w.threads, key: key}
+	w.job.lset(t.id, i.ID)
 
 	return
 }
+
+// postpone reschedules i, round up to next second since next_at is in seconds
+func (w *worker) postpone(i *model.Item, now time.Time, wait time.Duration) (next int64) {
+	next = now.Add(wait + time.Second - 1).Unix()
+	if err := w.Postpone(i.ID, i.Lease, next); err != nil {
+		w.Logger.Error("cannot postpone notification", itemArgs(i, "error", err)...)
+	}
+	return
+}
+
+// itemArgs prepends basic info of i to args of Logger
+func itemArgs(i *model.Item, args ...interface{}) (ret []interface{}) {
+	ret = make([]interface{}, 0, len(args)+6)
+	ret = append(ret, "id", i.ID, "driver", i.Driver, "endpoint", i.Endpoint)
+	return append(ret, args...)
+}
@@ -5,6 +5,10 @@
 package notify
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
 	"sync"
 	"time"
 
@@ -16,6 +20,7 @@ type data struct {
 	i   *model.Item
 	drv types.Driver
 	ch  chan *thread
+	key string // key of circuit breaker
 }
 
 type thread struct {
@@ -24,44 +29,173 @@ type thread struct {
 	wg  *sync.WaitGroup
 	id  uint16
 	job *jobCtrl
+	// parent context of sending, canceled when shutdown timed out
+	ctx context.Context
+	br  *breaker
+	m   *metrics
+	// results failed to write to db
+	journal *journal
+	// quarantines drivers which keep panicking
+	pauser *pauser
 }
 
+// panicError denotes the driver panicked when sending
+type panicError struct {
+	v interface{}
+}
+
+func (e *panicError) Error() string { return fmt.Sprintf("driver panicked: %v", e.v) }
+
 func (t *thread) mainloop() {
 	for d := range t.ch {
-		t.run(d.i, d.drv)
+		t.safeRun(d)
 		d.ch <- t
 	}
 
 	t.wg.Done()
 }
 
-func (t *thread) run(i *model.Item, drv types.Driver) {
+// safeRun runs d, and keeps the thread alive if anything panics outside the
+// driver, like hooks.
+func (t *thread) safeRun(d *data) {
+	defer func() {
+		if v := recover(); v != nil {
+			t.Logger.Error("recovered from panic", itemArgs(d.i, "panic", v, "stack", string(debug.Stack()))...)
+		}
+	}()
+
+	t.run(d)
+}
+
+func (t *thread) run(d *data) {
+	i, drv := d.i, d.drv
 	defer func() {
 		t.job.lset(t.id, "")
 	}()
 
 	now := time.Now()
-	next, stop := t.Scheduler(drv.Type(), i.ID, now, i.Tried)
+	max := t.MaxTries
+	if i.Retry.MaxTries > 0 {
+		max = i.Retry.MaxTries
+	}
+	if i.Resend {
+		max = 1
+	}
+	var (
+		next time.Time
+		stop bool
+	)
+	if i.Retry.Backoff != "" {
+		next = backoff(i.Retry, now, i.Used())
+	} else {
+		next, stop = t.Scheduler(drv.Type(), i.ID, now, i.Used())
+	}
 	state := types.PENDING
 	i.Tried++
-	i.NextAt = next.Unix()
-	if stop {
-		i.Tried = t.MaxTries
+
+	t.Logger.Debug("sending notification", itemArgs(i, "tried", i.Tried, "thread", t.id)...)
+	begin := time.Now()
+	resp, err := t.send(drv, i)
+	t.m.observe(drv.Type(), time.Since(begin), err)
+	t.quarantine(drv.Type(), err)
+	t.record(i, begin, resp, err)
+	t.attempted(i, resp, err)
+	perm := types.IsPermanent(err)
+	// permanent error is caused by the notification, not the endpoint
+	t.br.report(d.key, drv.Type(), err == nil || perm, time.Now())
+
+	if delay, ok := types.RetryDelay(err); ok {
+		next = time.Now().Add(delay)
 	}
-	if i.Tried >= t.MaxTries {
+	i.NextAt = next.Unix()
+
+	switch {
+	case err == nil:
+		state = types.SUCCESS
+	case perm, stop, i.Used() >= max:
 		state = types.FAILED
+	case i.ExpireAt > 0 && i.NextAt >= i.ExpireAt:
+		// next try will be too late
+		state = types.EXPIRED
+	}
+	if err != nil && len(resp) == 0 {
+		resp = []byte(err.Error())
 	}
 
-	resp, err := drv.Send(i.Endpoint, i.Content)
-
-	if err == nil {
-		state = types.SUCCESS
+	if state == types.PENDING {
+		t.Logger.Debug("rescheduled", itemArgs(i, "tried", i.Tried, "next_at", i.NextAt)...)
 	} else {
-		// TODO: log error
-		if len(resp) == 0 {
-			resp = []byte(err.Error())
+		t.Logger.Info("state changed", itemArgs(i, "tried", i.Tried, "state", state)...)
+	}
+	if state != types.PENDING {
+		t.callback(i, state, resp)
+	}
+	t.update(i, state, resp)
+	i.State = state
+	t.finished(i)
+}
+
+func (t *thread) timeout(typ string) (ret time.Duration) {
+	if x, ok := t.SendTimeouts[typ]; ok {
+		return x
+	}
+	return t.SendTimeout
+}
+
+// quarantine pauses the driver if it keeps panicking
+func (t *thread) quarantine(typ string, err error) {
+	if _, ok := err.(*panicError); !ok {
+		t.pauser.sent(typ)
+		return
+	}
+
+	if t.pauser.panicked(typ, t.QuarantineThreshold) {
+		t.Logger.Error("quarantined driver, resume it after fixing", "driver", typ, "panics", t.QuarantineThreshold)
+	}
+}
+
+// send sends i with drv. If drv panics, the panic and stack are returned as
+// response with a *panicError.
+func (t *thread) send(drv types.Driver, i *model.Item) (resp []byte, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &panicError{v: v}
+			resp = []byte(fmt.Sprintf("panic: %v\n\n%s", v, debug.Stack()))
 		}
+	}()
+
+	d, ok := drv.(types.ContextDriver)
+	if !ok {
+		return drv.Send(i.Endpoint, i.Content)
+	}
+
+	ctx := t.ctx
+	if x := t.timeout(drv.Type()); x > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, x)
+		defer cancel()
 	}
 
-	t.Update(i.ID, i.Tried, i.NextAt, state, resp)
+	return d.SendContext(ctx, i.Endpoint, i.Content)
+}
+
+func (t *thread) record(i *model.Item, begin time.Time, resp []byte, err error) {
+	a := types.Attempt{
+		Tried:    i.Tried,
+		At:       begin.Unix(),
+		Duration: int64(time.Since(begin) / time.Millisecond),
+		Response: resp,
+		Thread:   t.NodeID + "/" + strconv.Itoa(int(t.id)),
+	}
+	args := itemArgs(i, "tried", a.Tried, "duration", a.Duration)
+	if err != nil {
+		a.Error = err.Error()
+		t.Logger.Warn("attempt failed", append(args, "error", err)...)
+	} else {
+		t.Logger.Info("attempt succeeded", args...)
+	}
+
+	if err := t.AddAttempt(i.ID, a); err != nil {
+		t.Logger.Error("cannot record attempt", append(args, "error", err)...)
+	}
 }
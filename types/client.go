@@ -22,15 +22,27 @@ type Client interface {
 	With(ctx context.Context) (ret Client)
 
 	// maps api endpoints to function
-	Send(id string, driver string, ep string, payload interface{}) (err error)
-	SendOnce(id string, driver string, ep string, payload interface{}) (err error)
+	Send(id string, driver string, ep string, payload interface{}, opts ...SendOption) (err error)
+	SendOnce(id string, driver string, ep string, payload interface{}, opts ...SendOption) (err error)
+	// sends to every target, returns ids of created notifications
+	Broadcast(group string, targets []Target, opts ...SendOption) (ret []string, err error)
+	GroupStatus(group string) (ret GroupStatus, err error)
 	Resend(id string) (err error)
 	Result(id string) (ret []byte, err error)
 	Status(id string) (ret Status, err error)
 	Detail(id string) (ret Detail, err error)
+	Attempts(id string) (ret []Attempt, err error)
 	Delete(id string) (err error)
 	Clear(before time.Time) (err error)
 	ForceClear(before time.Time) (err error)
+	Failed(f Filter) (ret []Failed, err error)
+	Requeue(f Filter) (ret int64, err error)
+	Breakers() (ret []BreakerStatus, err error)
+	// pauses driver, or all drivers if driver is empty
+	Pause(driver string) (err error)
+	// resumes driver, or all drivers if driver is empty
+	Resume(driver string) (err error)
+	Paused() (ret PauseStatus, err error)
 }
 
 // NewClient creates a Client
@@ -116,25 +128,118 @@ func (c *client) query(path string, data, ret interface{}) (err error) {
 	return
 }
 
-func (c *client) Send(id string, driver string, ep string, payload interface{}) (err error) {
-	data := map[string]interface{}{
-		"id":       id,
-		"type":     driver,
-		"endpoint": ep,
-		"payload":  payload,
+// SendOption sets optional parameters of Send and SendOnce
+type SendOption func(p *Params)
+
+// WithSendAt schedules the notification to be sent at t
+func WithSendAt(t time.Time) SendOption {
+	return func(p *Params) { p.SendAt = Timestamp(t.Unix()) }
+}
+
+// WithExpireAt stops sending the notification after t
+func WithExpireAt(t time.Time) SendOption {
+	return func(p *Params) { p.ExpireAt = Timestamp(t.Unix()) }
+}
+
+// WithTTL stops sending the notification after d since it is scheduled to send
+func WithTTL(d time.Duration) SendOption {
+	return func(p *Params) { p.TTL = uint32(d / time.Second) }
+}
+
+// WithRetry overrides retry behavior of the server, ignored by SendOnce
+func WithRetry(r RetryPolicy) SendOption {
+	return func(p *Params) { p.Retry = &r }
+}
+
+// WithPriority sets priority of the notification, see Params for detail
+func WithPriority(prio int) SendOption {
+	return func(p *Params) { p.Priority = prio }
+}
+
+// WithCallback posts final status of the notification to cb.URL, see Callback
+// for detail
+func WithCallback(cb Callback) SendOption {
+	return func(p *Params) { p.Callback = &cb }
+}
+
+func (c *client) send(path, id, driver, ep string, payload interface{}, opts []SendOption) (err error) {
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	p := &Params{
+		ID:       id,
+		Driver:   driver,
+		Endpoint: ep,
+		Payload:  buf,
+	}
+	for _, o := range opts {
+		o(p)
+	}
+
+	return c.create(path, id, p, nil)
+}
+
+// create calls /send, /sendOnce or /broadcast, converts 409 to ConflictError
+func (c *client) create(path, id string, data, ret interface{}) (err error) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(
+		c.ctx, "POST", c.host+path, bytes.NewReader(buf),
+	)
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	defer io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		// /broadcast responds GroupMember, which is a superset of Status
+		var m GroupMember
+		if err = json.NewDecoder(resp.Body).Decode(&m); err == nil {
+			if m.ID == "" {
+				m.ID = id
+			}
+			err = &ConflictError{ID: m.ID, Status: m.Status}
+		}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		err = fmt.Errorf("failed to call %s: %d", path, resp.StatusCode)
+	case ret != nil:
+		err = json.NewDecoder(resp.Body).Decode(ret)
 	}
+	return
+}
 
-	return c.exec("/send", data)
+func (c *client) Send(id string, driver string, ep string, payload interface{}, opts ...SendOption) (err error) {
+	return c.send("/send", id, driver, ep, payload, opts)
 }
-func (c *client) SendOnce(id string, driver string, ep string, payload interface{}) (err error) {
-	data := map[string]interface{}{
-		"id":       id,
-		"type":     driver,
-		"endpoint": ep,
-		"payload":  payload,
+func (c *client) SendOnce(id string, driver string, ep string, payload interface{}, opts ...SendOption) (err error) {
+	return c.send("/sendOnce", id, driver, ep, payload, opts)
+}
+func (c *client) Broadcast(group string, targets []Target, opts ...SendOption) (ret []string, err error) {
+	p := &BroadcastParams{Targets: targets}
+	p.ID = group
+	for _, o := range opts {
+		o(&p.Params)
 	}
 
-	return c.exec("/sendOnce", data)
+	err = c.create("/broadcast", group, p, &ret)
+	return
+}
+func (c *client) GroupStatus(group string) (ret GroupStatus, err error) {
+	data := map[string]interface{}{"id": group}
+	err = c.query("/groupStatus", data, &ret)
+	return
 }
 func (c *client) Resend(id string) (err error) {
 	data := map[string]interface{}{"id": id}
@@ -174,6 +279,12 @@ func (c *client) Detail(id string) (ret Detail, err error) {
 	err = c.query("/detail", data, &ret)
 	return
 }
+func (c *client) Attempts(id string) (ret []Attempt, err error) {
+	data := map[string]interface{}{"id": id}
+	err = c.query("/attempts", data, &ret)
+	return
+}
+
 func (c *client) Delete(id string) (err error) {
 	data := map[string]interface{}{"id": id}
 	return c.exec("/delete", data)
@@ -186,3 +297,32 @@ func (c *client) ForceClear(before time.Time) (err error) {
 	data := map[string]interface{}{"before": before.Unix()}
 	return c.exec("/forceClear", data)
 }
+func (c *client) Failed(f Filter) (ret []Failed, err error) {
+	err = c.query("/failed", f, &ret)
+	return
+}
+func (c *client) Requeue(f Filter) (ret int64, err error) {
+	var x struct {
+		Count int64 `json:"count"`
+	}
+	err = c.query("/requeue", f, &x)
+	ret = x.Count
+	return
+}
+func (c *client) Breakers() (ret []BreakerStatus, err error) {
+	err = c.query("/breakers", map[string]interface{}{}, &ret)
+	return
+}
+
+func (c *client) Pause(driver string) (err error) {
+	data := map[string]interface{}{"type": driver}
+	return c.exec("/pause", data)
+}
+func (c *client) Resume(driver string) (err error) {
+	data := map[string]interface{}{"type": driver}
+	return c.exec("/resume", data)
+}
+func (c *client) Paused() (ret PauseStatus, err error) {
+	err = c.query("/paused", map[string]interface{}{}, &ret)
+	return
+}
@@ -0,0 +1,96 @@
+/* This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0. If a copy of the MPL was not distributed with this
+ * file, You can obtain one at https://mozilla.org/MPL/2.0/. */
+
+package types
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PermanentError denotes the notification can never be delivered, like invalid
+// phone number or rejected by remote server. Sender marks it as FAILED at once
+// instead of retrying.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return "permanent error: " + e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// Permanent wraps err in a PermanentError, returns nil if err is nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// RetryAfterError denotes the endpoint asks to retry after Delay, like HTTP 429
+// with Retry-After header. Sender uses it instead of result of Scheduler.
+type RetryAfterError struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return "retry after " + e.Delay.String() + ": " + e.Err.Error()
+}
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// RetryAfter wraps err in a RetryAfterError, returns nil if err is nil
+func RetryAfter(d time.Duration, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &RetryAfterError{Delay: d, Err: err}
+}
+
+// ConflictError is returned by Client.Send, Client.SendOnce and
+// Client.Broadcast if id is used by another notification with different driver,
+// endpoint or payload. Sending identical notification again is not an error, so
+// it is safe to retry.
+type ConflictError struct {
+	ID string
+	// status of existing notification
+	Status Status
+}
+
+func (e *ConflictError) Error() string {
+	return "notification " + e.ID + " exists with different content"
+}
+
+// IsPermanent reports whether any error in err's chain is a PermanentError
+func IsPermanent(err error) bool {
+	var e *PermanentError
+	return errors.As(err, &e)
+}
+
+// RetryDelay returns Delay of first RetryAfterError in err's chain
+func RetryDelay(err error) (ret time.Duration, ok bool) {
+	var e *RetryAfterError
+	if ok = errors.As(err, &e); ok {
+		ret = e.Delay
+	}
+	return
+}
+
+// ParseRetryAfter parses value of HTTP Retry-After header, which is either
+// seconds or a HTTP date.
+func ParseRetryAfter(v string, now time.Time) (ret time.Duration, ok bool) {
+	if sec, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return time.Duration(sec) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return
+	}
+	if ret = t.Sub(now); ret < 0 {
+		ret = 0
+	}
+	return ret, true
+}
@@ -6,7 +6,10 @@
 package types
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -17,9 +20,25 @@ const (
 	PENDING State = iota // notification is waiting to (re)send
 	SUCCESS              // notification is sent to the endpoint
 	FAILED               // notification is failed to send after all
+	EXPIRED              // notification is not delivered before its deadline
 )
 
+func (s State) String() string {
+	switch s {
+	case PENDING:
+		return "PENDING"
+	case SUCCESS:
+		return "SUCCESS"
+	case FAILED:
+		return "FAILED"
+	case EXPIRED:
+		return "EXPIRED"
+	}
+	return "State(" + strconv.Itoa(int(s)) + ")"
+}
+
 // Scheduler is an user-defined function to determine when to resend notification
+// tried counts tries since last /resend or /requeue.
 type Scheduler func(driver, notifyID string, lastExec time.Time, tried uint32) (next time.Time, stop bool)
 
 // Params defines required parameters of API endpoint /send and /sendOnce
@@ -32,6 +51,133 @@ type Params struct {
 	Endpoint string `json:"endpoint"`
 	// driver specific parameters. see docs of the driver for detail
 	Payload json.RawMessage `json:"payload"`
+	// notifications with higher priority are sent first, default to 0.
+	// See model.PriorityAging for how lower priorities are protected from
+	// starvation.
+	Priority int `json:"priority,omitempty"`
+	// do not send before this time, default to send immediately
+	SendAt Timestamp `json:"send_at,omitempty"`
+	// stop sending after this time, default to no deadline
+	ExpireAt Timestamp `json:"expire_at,omitempty"`
+	// same as ExpireAt, but in seconds after SendAt (or now if SendAt is not
+	// set). The earlier one is used if both are set.
+	TTL uint32 `json:"ttl,omitempty"`
+	// overrides retry behavior of the server, /sendOnce ignores this
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// notifies the caller when the notification is finished
+	Callback *Callback `json:"callback,omitempty"`
+}
+
+// Target defines a destination of /broadcast
+type Target struct {
+	Driver   string          `json:"type"`
+	Endpoint string          `json:"endpoint"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// BroadcastParams defines parameters of /broadcast. ID is used as group ID, and
+// a child notification with ID "GROUP_ID#INDEX" (like "alert#000") is created
+// for each target, with other fields in Params. Driver, Endpoint and Payload
+// in Params are ignored.
+type BroadcastParams struct {
+	Params
+	Targets []Target `json:"targets"`
+}
+
+// Aggregated state of broadcast, see GroupStatus
+const (
+	GroupPending   = "pending"   // some notifications are still pending
+	GroupSucceeded = "succeeded" // all notifications are sent
+	GroupPartial   = "partial"   // finished, but some are not sent
+	GroupFailed    = "failed"    // finished, and none is sent
+)
+
+// GroupMember defines status of a notification in broadcast group
+type GroupMember struct {
+	ID       string `json:"id"`
+	Driver   string `json:"type"`
+	Endpoint string `json:"endpoint"`
+	Status
+}
+
+// GroupStatus defines response type of /groupStatus
+type GroupStatus struct {
+	// one of GroupPending, GroupSucceeded, GroupPartial or GroupFailed
+	State string `json:"state"`
+	// number of notifications in each state
+	Pending uint32 `json:"pending"`
+	Success uint32 `json:"success"`
+	Failed  uint32 `json:"failed"`
+	Expired uint32 `json:"expired"`
+	Members []GroupMember `json:"members"`
+}
+
+// Callback defines where to POST CallbackBody in JSON format when notification
+// reaches terminal state (SUCCESS, FAILED or EXPIRED). The callback is sent as
+// another notification with ID "callback:{id}#{tried}:{state}" (hashed if too
+// long), and retries like normal notifications. It is sent again when the
+// notification reaches another terminal state after /resend or /requeue.
+type Callback struct {
+	// http or https url
+	URL string `json:"url"`
+	// additional headers to send
+	Headers http.Header `json:"headers,omitempty"`
+}
+
+// CallbackBody defines the request body of Callback
+type CallbackBody struct {
+	ID       string `json:"id"`
+	Driver   string `json:"type"`
+	Endpoint string `json:"endpoint"`
+	// last response
+	Response []byte `json:"response"`
+	Status
+}
+
+// Backoff kinds supported by RetryPolicy
+const (
+	BackoffFixed       = "fixed"       // waits Base every time
+	BackoffLinear      = "linear"      // waits Base * tries
+	BackoffExponential = "exponential" // waits Base * 2^(tries-1)
+)
+
+// RetryPolicy defines how a notification is retried. Zero values fall back to
+// settings of the server.
+type RetryPolicy struct {
+	// max number of tries, including the first one
+	MaxTries uint32 `json:"max_tries,omitempty"`
+	// one of BackoffFixed, BackoffLinear or BackoffExponential, Scheduler of
+	// the server is used if empty
+	Backoff string `json:"backoff,omitempty"`
+	// base delay in seconds, default to 60
+	Base uint32 `json:"base,omitempty"`
+	// max delay in seconds, 0 means no limit
+	Cap uint32 `json:"cap,omitempty"`
+}
+
+// Timestamp is unix timestamp in seconds. It accepts either a number or a string
+// in RFC 3339 format when decoding from JSON, and is always encoded as number.
+type Timestamp int64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Timestamp) UnmarshalJSON(data []byte) (err error) {
+	if len(data) == 0 || data[0] != '"' {
+		var ts *int64
+		if err = json.Unmarshal(data, &ts); err == nil && ts != nil {
+			*t = Timestamp(*ts)
+		}
+		return
+	}
+
+	var str string
+	if err = json.Unmarshal(data, &str); err != nil || str == "" {
+		return
+	}
+	x, err := time.Parse(time.RFC3339, str)
+	if err == nil {
+		*t = Timestamp(x.Unix())
+	}
+	return
 }
 
 // Driver defines the interface a driver must implement
@@ -39,7 +185,8 @@ type Driver interface {
 	// driver type, 3rd party drivers *SHOULD* use go import path format like
 	// github.com/some_org/some_proj/DRIVER_NAME
 	Type() string
-	// send the notification
+	// send the notification. Return PermanentError or RetryAfterError to
+	// change how sender retries it.
 	Send(ep string, content []byte) (resp []byte, err error)
 	// check the payload format. content is in json format
 	Verify(content []byte) (err error)
@@ -47,12 +194,86 @@ type Driver interface {
 	CheckEP(ep string) (err error)
 }
 
+// ContextDriver is an optional interface a Driver can implement to support
+// timeout and cancellation. Sender prefers SendContext if it is implemented.
+type ContextDriver interface {
+	Driver
+	// send the notification, abort as soon as possible if ctx is done
+	SendContext(ctx context.Context, ep string, content []byte) (resp []byte, err error)
+}
+
+// BreakerKeyer is an optional interface a Driver can implement to decide which
+// endpoints share same circuit breaker, like all urls on same host. Default key
+// is driver type plus endpoint.
+type BreakerKeyer interface {
+	BreakerKey(ep string) (key string)
+}
+
+// HealthChecker is an optional interface a Driver can implement to report
+// whether it is able to send, like validating api token. It is used by /readyz.
+type HealthChecker interface {
+	Health(ctx context.Context) (err error)
+}
+
+// Health defines response type of /healthz and /readyz
+type Health struct {
+	OK bool `json:"ok"`
+	// keys are "sender", "db" or "driver:" + driver type
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// ComponentHealth is health of a component, see Health
+type ComponentHealth struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// PauseStatus defines response type of /paused
+type PauseStatus struct {
+	// every driver is paused
+	All bool `json:"all"`
+	// paused driver types
+	Drivers []string `json:"types"`
+}
+
+// BreakerStatus defines response type of /breakers
+type BreakerStatus struct {
+	Key string `json:"key"`
+	// driver type of last notification using this breaker
+	Driver string `json:"type"`
+	// consecutive failures
+	Failures uint32 `json:"failures"`
+	// "closed", "open" or "half-open"
+	State string `json:"state"`
+	// notifications are skipped until this time if state is "open"
+	OpenUntil int64 `json:"open_until"`
+}
+
 // Status defines response type of /status
 type Status struct {
 	CreateAt int64  `json:"create_at"`
 	NextAt   int64  `json:"next_at"`
 	Tried    uint32 `json:"tried"`
 	State    State  `json:"state"`
+	// scheduled time of first try, 0 if it is sent immediately
+	SendAt int64 `json:"send_at,omitempty"`
+	// deadline of the notification, 0 if it never expires
+	ExpireAt int64 `json:"expire_at,omitempty"`
+}
+
+// Attempt defines an element of response of /attempts
+type Attempt struct {
+	// nth try of the notification, begins with 1
+	Tried uint32 `json:"tried"`
+	// unix timestamp when it begins
+	At int64 `json:"at"`
+	// time spent on sending, in milliseconds
+	Duration int64 `json:"duration"`
+	// error message, empty if succeeded
+	Error    string `json:"error,omitempty"`
+	Response []byte `json:"response"`
+	// node id and thread number of the sender, like "node-1/3"
+	Thread string `json:"thread"`
 }
 
 // Detail defines response type of /detail
@@ -63,3 +284,32 @@ type Detail struct {
 	Response []byte `json:"response"`
 	Status
 }
+
+// Failed defines an element of response of /failed
+type Failed struct {
+	ID string `json:"id"`
+	Detail
+}
+
+// Filter selects FAILED notifications in /failed and /requeue, zero values
+// match everything.
+type Filter struct {
+	Driver   string `json:"type,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// created at or after this time
+	After Timestamp `json:"after,omitempty"`
+	// created before this time
+	Before Timestamp `json:"before,omitempty"`
+	// pagination of /failed, ignored by /requeue. Limit defaults to 100 and
+	// is at most 1000.
+	Limit  uint32 `json:"limit,omitempty"`
+	Offset uint32 `json:"offset,omitempty"`
+	// /requeue rejects empty filter to prevent requeueing everything by
+	// mistake, set All to true to do it.
+	All bool `json:"all,omitempty"`
+}
+
+// Empty returns true if f matches every notification.
+func (f Filter) Empty() (ret bool) {
+	return f.Driver == "" && f.Endpoint == "" && f.After == 0 && f.Before == 0
+}